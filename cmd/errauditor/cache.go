@@ -0,0 +1,174 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"github.com/thedhejavu/errauditor/errauditor"
+)
+
+// version is the errauditor version, set with -ldflags "-X main.version=...".
+var version = "devel"
+
+// cache stores per package results under the user cache directory. Packages
+// are keyed by the contents of their files and the keys of the audited
+// packages they import, so a change to a package also invalidates its
+// dependents. A nil cache never hits.
+type cache struct {
+	dir  string
+	keys map[string]string
+}
+
+func newCache(pkgs []*build.Package, files, imports func(*build.Package) []string, options string) *cache {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		logger.Debugf("failed to locate cache dir: %s", err)
+		return nil
+	}
+	c := &cache{
+		dir:  filepath.Join(dir, "errauditor"),
+		keys: make(map[string]string, len(pkgs)),
+	}
+
+	byPath := make(map[string]*build.Package, len(pkgs))
+	for _, pkg := range pkgs {
+		byPath[importPathForDir(pkg.Dir)] = pkg
+	}
+	salt := toolVersion() + "\x00" + runtime.Version() + "\x00" + strings.Join(build.Default.BuildTags, ",") + "\x00" + options
+
+	var visit func(pkgPath string) string
+	visiting := make(map[string]bool)
+	visit = func(pkgPath string) string {
+		if key, ok := c.keys[pkgPath]; ok {
+			return key
+		}
+		if visiting[pkgPath] {
+			return ""
+		}
+		visiting[pkgPath] = true
+		pkg := byPath[pkgPath]
+
+		h := sha256.New()
+		fmt.Fprintf(h, "%s\x00%s\x00", salt, pkgPath)
+		names := files(pkg)
+		sort.Strings(names)
+		for _, name := range names {
+			f, err := os.Open(filepath.Join(pkg.Dir, name))
+			if err != nil {
+				// do not cache packages that cannot be read entirely.
+				c.keys[pkgPath] = ""
+				return ""
+			}
+			fmt.Fprintf(h, "%s\x00", name)
+			_, err = io.Copy(h, f)
+			f.Close()
+			if err != nil {
+				c.keys[pkgPath] = ""
+				return ""
+			}
+		}
+		deps := imports(pkg)
+		sort.Strings(deps)
+		for _, imp := range deps {
+			if _, ok := byPath[imp]; ok {
+				fmt.Fprintf(h, "%s\x00%s\x00", imp, visit(imp))
+			}
+		}
+
+		key := hex.EncodeToString(h.Sum(nil))
+		c.keys[pkgPath] = key
+		return key
+	}
+	for pkgPath := range byPath {
+		visit(pkgPath)
+	}
+	return c
+}
+
+// toolVersion identifies the errauditor build so that results are not reused
+// across versions of the analysis.
+func toolVersion() string {
+	v := version
+	if info, ok := debug.ReadBuildInfo(); ok {
+		v += "+" + info.Main.Version
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" || setting.Key == "vcs.modified" {
+				v += "+" + setting.Value
+			}
+		}
+	}
+	return v
+}
+
+func (c *cache) path(pkgPath string) (string, bool) {
+	if c == nil || c.keys[pkgPath] == "" {
+		return "", false
+	}
+	key := c.keys[pkgPath]
+	return filepath.Join(c.dir, key[:2], key+".json"), true
+}
+
+// get returns the cached result of the package.
+func (c *cache) get(pkgPath string) (*errauditor.Result, bool) {
+	path, ok := c.path(pkgPath)
+	if !ok {
+		return nil, false
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var result errauditor.Result
+	if err := json.Unmarshal(data, &result); err != nil {
+		logger.Debugf("failed to decode cache entry %s: %s", path, err)
+		return nil, false
+	}
+	return &result, true
+}
+
+// put stores the result of the package. Failures are not fatal, the package
+// is audited again on the next run.
+func (c *cache) put(pkgPath string, result *errauditor.Result) {
+	path, ok := c.path(pkgPath)
+	if !ok {
+		return
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		logger.Debugf("failed to encode cache entry: %s", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		logger.Debugf("failed to create cache dir: %s", err)
+		return
+	}
+	// write to a temporary file first so that concurrent runs never read a
+	// partial entry.
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "tmp-")
+	if err != nil {
+		logger.Debugf("failed to write cache entry: %s", err)
+		return
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		logger.Debugf("failed to write cache entry: %s", err)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thedhejavu/errauditor/errauditor"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(src), 0o644))
+	}
+}
+
+func TestCacheKeys(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":        "module example.com/project\n",
+		"usecase.go":    "package project\n\nimport _ \"example.com/project/repo\"\n",
+		"repo/repo.go":  "package repo\n",
+		"other/main.go": "package other\n",
+	})
+
+	a := &app{}
+	keys := func() map[string]string {
+		var pkgs []*build.Package
+		for _, d := range []string{".", "repo", "other"} {
+			pkg, err := build.ImportDir(filepath.Join(dir, d), 0)
+			require.NoError(t, err)
+			pkgs = append(pkgs, pkg)
+		}
+		c := newCache(pkgs, a.files, a.imports, a.cacheOptions())
+		require.NotNil(t, c)
+		return c.keys
+	}
+
+	before := keys()
+	require.Len(t, before, 3)
+	writeFiles(t, dir, map[string]string{"repo/repo.go": "package repo\n\nvar X = 1\n"})
+	after := keys()
+
+	// the changed package and its dependents are audited again.
+	require.NotEqual(t, before["example.com/project/repo"], after["example.com/project/repo"])
+	require.NotEqual(t, before["example.com/project"], after["example.com/project"])
+	require.Equal(t, before["example.com/project/other"], after["example.com/project/other"])
+}
+
+func TestCacheRoundTrip(t *testing.T) {
+	c := &cache{dir: t.TempDir(), keys: map[string]string{"example.com/project": "0123456789abcdef"}}
+	result := &errauditor.Result{
+		AggregatedErrors: []*errauditor.AggregatedError{
+			{Package: "example.com/project", Func: "GetAddressByUser", Errors: []*errauditor.ErrorEntry{
+				{Expr: "apperrors.ErrRecordNotFound", Kind: errauditor.ConstError, Text: "ErrRecordNotFound()"},
+			}},
+		},
+		ConstErrorCount: 1,
+	}
+
+	_, ok := c.get("example.com/project")
+	require.False(t, ok)
+	c.put("example.com/project", result)
+	cached, ok := c.get("example.com/project")
+	require.True(t, ok)
+	require.Equal(t, result, cached)
+
+	var disabled *cache
+	disabled.put("example.com/project", result)
+	_, ok = disabled.get("example.com/project")
+	require.False(t, ok)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/thedhejavu/errauditor/errauditor"
+)
+
+// diff audits two git revisions and reports the errors added to or removed
+// from the exported functions. It exits with 1 when any error was added.
+func (a *app) diff(args []string) int {
+	if len(args) < 2 {
+		logger.Errorf("usage: errauditor diff <rev1> <rev2> [packages]")
+		return 2
+	}
+	oldResult, err := a.auditRevision(args[0], args[2:])
+	if err != nil {
+		logger.Errorf("failed to audit %s: %s", args[0], err)
+		return 1
+	}
+	newResult, err := a.auditRevision(args[1], args[2:])
+	if err != nil {
+		logger.Errorf("failed to audit %s: %s", args[1], err)
+		return 1
+	}
+
+	diffs := errauditor.DiffResults(oldResult, newResult)
+	if err := errauditor.WriteDiff(os.Stdout, diffs); err != nil {
+		logger.Errorf("failed to write diff: %s", err)
+		return 1
+	}
+	for _, diff := range diffs {
+		if len(diff.Added) > 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// auditRevision checks out rev into a temporary worktree and audits it from
+// the directory matching the current working directory.
+func (a *app) auditRevision(rev string, args []string) (_ *errauditor.Result, err error) {
+	prefix, err := git("rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := ioutil.TempDir("", "errauditor-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	tree := filepath.Join(tmp, "tree")
+	if _, err := git("worktree", "add", "--detach", tree, rev); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if _, rerr := git("worktree", "remove", "--force", tree); err == nil {
+			err = rerr
+		}
+	}()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chdir(filepath.Join(tree, prefix)); err != nil {
+		return nil, err
+	}
+	defer os.Chdir(wd)
+
+	a.auditor = a.newAuditor()
+	if err := a.check(args); err != nil {
+		return nil, err
+	}
+	return a.auditor.Result(), nil
+}
+
+// git runs a git command and returns its trimmed output.
+func git(args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/thedhejavu/errauditor/errauditor"
+)
+
+// grpc audits the packages and writes the gRPC codes each RPC method may
+// return, as a table or as JSON.
+func (a *app) grpc(args []string) int {
+	var write func(io.Writer, []*errauditor.RPCCodes) error
+	switch a.format {
+	case "text":
+		write = errauditor.WriteGRPCCodes
+	case "json":
+		write = errauditor.WriteGRPCCodesJSON
+	default:
+		logger.Errorf("failed to run with: %s", fmt.Errorf("unknown format %q for grpc", a.format))
+		return 2
+	}
+	if err := a.check(args); err != nil {
+		logger.Errorf("failed to run with: %s", err)
+		return 1
+	}
+	result := a.auditor.Result()
+	if a.newFromRev != "" || a.newFromPatch != "" {
+		if err := a.filterChanges(result); err != nil {
+			logger.Errorf("failed to read changes: %s", err)
+			return 1
+		}
+	}
+	rpcs, err := result.GRPCCodes(a.grpcMappings...)
+	if err != nil {
+		logger.Errorf("failed to audit gRPC codes: %s", err)
+		return 1
+	}
+
+	w := io.Writer(os.Stdout)
+	var f *os.File
+	if a.output != "" {
+		f, err = os.Create(a.output)
+		if err != nil {
+			logger.Errorf("failed to write codes: %s", err)
+			return 1
+		}
+		w = f
+	}
+	err = write(w, rpcs)
+	// the file is closed explicitly, a failed flush truncates it.
+	if f != nil {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		logger.Errorf("failed to write codes: %s", err)
+		return 1
+	}
+	return 0
+}
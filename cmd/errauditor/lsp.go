@@ -0,0 +1,521 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/thedhejavu/errauditor/errauditor"
+)
+
+// lspServer is a minimal language server speaking JSON-RPC over stdio. It
+// publishes the findings of the auditor as diagnostics and shows the errors a
+// function may return when hovering its name or a call to it.
+type lspServer struct {
+	app      *app
+	ws       *workspace
+	in       *bufio.Reader
+	out      io.Writer
+	result   *errauditor.Result
+	shutdown bool
+	// published holds the files that have diagnostics in the client.
+	published map[string]bool
+	// dirty holds the files edited since their package was last audited.
+	// They are audited again when saved, or before a hover needs them, not
+	// on every keystroke.
+	dirty map[string]bool
+}
+
+type lspRequest struct {
+	ID     *json.RawMessage `json:"id"`
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params"`
+}
+
+type lspResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  interface{}      `json:"result"`
+}
+
+type lspErrorResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Error   lspError         `json:"error"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspTextDocument struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Code     string   `json:"code"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+const (
+	lspMethodNotFound   = -32601
+	lspInvalidParams    = -32602
+	lspSeverityWarning  = 2
+	lspTextDocumentFull = 1
+)
+
+// lsp serves the language server protocol until the client exits.
+func (a *app) lsp(in io.Reader, out io.Writer) int {
+	a.overlay = make(map[string][]byte)
+	s := &lspServer{
+		app:       a,
+		in:        bufio.NewReader(in),
+		out:       out,
+		published: make(map[string]bool),
+		dirty:     make(map[string]bool),
+	}
+	for {
+		req, err := s.read()
+		if err != nil {
+			if err != io.EOF {
+				logger.Errorf("failed to read message: %s", err)
+			}
+			return 1
+		}
+		if req.Method == "exit" {
+			if s.shutdown {
+				return 0
+			}
+			return 1
+		}
+		result, rerr := s.handle(req)
+		if req.ID == nil {
+			if rerr != nil {
+				logger.Debugf("failed to handle %s: %s", req.Method, rerr.Message)
+			}
+			continue
+		}
+		if rerr != nil {
+			err = s.write(lspErrorResponse{JSONRPC: "2.0", ID: req.ID, Error: *rerr})
+		} else {
+			err = s.write(lspResponse{JSONRPC: "2.0", ID: req.ID, Result: result})
+		}
+		if err != nil {
+			logger.Errorf("failed to write message: %s", err)
+			return 1
+		}
+	}
+}
+
+func (s *lspServer) read() (*lspRequest, error) {
+	header, err := textproto.NewReader(s.in).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Length: %v", err)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.in, body); err != nil {
+		return nil, err
+	}
+	var req lspRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func (s *lspServer) write(msg interface{}) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+func (s *lspServer) handle(req *lspRequest) (interface{}, *lspError) {
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			RootURI  string `json:"rootUri"`
+			RootPath string `json:"rootPath"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &lspError{Code: lspInvalidParams, Message: err.Error()}
+		}
+		root := params.RootPath
+		if params.RootURI != "" {
+			root = uriToPath(params.RootURI)
+		}
+		if root == "" {
+			root = "."
+		}
+		root, err := filepath.Abs(root)
+		if err != nil {
+			return nil, &lspError{Code: lspInvalidParams, Message: err.Error()}
+		}
+		s.ws, err = newWorkspace(s.app, []string{filepath.ToSlash(root) + "/..."})
+		if err != nil {
+			return nil, &lspError{Code: lspInvalidParams, Message: err.Error()}
+		}
+		s.result = s.ws.snapshot()
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync": map[string]interface{}{
+					"openClose": true,
+					"change":    lspTextDocumentFull,
+					"save":      map[string]bool{"includeText": false},
+				},
+				"hoverProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "errauditor"},
+		}, nil
+	case "initialized":
+		s.publish("")
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen", "textDocument/didChange", "textDocument/didSave", "textDocument/didClose":
+		var params struct {
+			TextDocument   lspTextDocument `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &lspError{Code: lspInvalidParams, Message: err.Error()}
+		}
+		path := uriToPath(params.TextDocument.URI)
+		switch req.Method {
+		case "textDocument/didOpen":
+			s.app.overlay[path] = []byte(params.TextDocument.Text)
+		case "textDocument/didChange":
+			if n := len(params.ContentChanges); n > 0 {
+				s.app.overlay[path] = []byte(params.ContentChanges[n-1].Text)
+			}
+			s.dirty[path] = true
+			return nil, nil
+		case "textDocument/didClose":
+			delete(s.app.overlay, path)
+		}
+		s.update(path)
+		return nil, nil
+	case "textDocument/hover":
+		var params struct {
+			TextDocument lspTextDocument `json:"textDocument"`
+			Position     lspPosition     `json:"position"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &lspError{Code: lspInvalidParams, Message: err.Error()}
+		}
+		return s.hover(uriToPath(params.TextDocument.URI), params.Position), nil
+	}
+	if req.ID != nil {
+		return nil, &lspError{Code: lspMethodNotFound, Message: "method not found: " + req.Method}
+	}
+	return nil, nil
+}
+
+// flush audits the packages of the edited files again.
+func (s *lspServer) flush() {
+	for path := range s.dirty {
+		s.update(path)
+	}
+}
+
+// update audits the package of the changed file again and publishes the
+// diagnostics of the package.
+func (s *lspServer) update(path string) {
+	delete(s.dirty, path)
+	if s.ws == nil || !strings.HasSuffix(path, ".go") {
+		return
+	}
+	pkgPath, ok := s.ws.packageOf(filepath.Dir(path))
+	if !ok {
+		return
+	}
+	s.ws.reload(pkgPath)
+	s.result = s.ws.snapshot()
+	s.publish(pkgPath)
+	if !s.published[path] {
+		// clear stale diagnostics of the changed file.
+		s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+			"uri":         pathToURI(path),
+			"diagnostics": []lspDiagnostic{},
+		})
+	}
+}
+
+// publish sends the diagnostics of the package, or of all packages when
+// pkgPath is empty, and clears files that no longer have findings.
+func (s *lspServer) publish(pkgPath string) {
+	diagnostics := make(map[string][]lspDiagnostic)
+	sources := make(map[string][]byte)
+	for _, finding := range s.result.Findings {
+		if pkgPath != "" && finding.Package != pkgPath {
+			continue
+		}
+		path, err := filepath.Abs(finding.Pos.Filename)
+		if err != nil {
+			continue
+		}
+		src, ok := sources[path]
+		if !ok {
+			src, _ = s.app.readFile(path)
+			sources[path] = src
+		}
+		start := lspPosition{Line: finding.Pos.Line - 1, Character: utf16Column(lineOf(src, finding.Pos.Line), finding.Pos.Column)}
+		diagnostics[path] = append(diagnostics[path], lspDiagnostic{
+			Range:    lspRange{Start: start, End: start},
+			Severity: lspSeverityWarning,
+			Code:     finding.Rule,
+			Source:   "errauditor",
+			Message:  finding.Message,
+		})
+	}
+	for path := range s.published {
+		if _, ok := diagnostics[path]; !ok && (pkgPath == "" || s.ws.dirs[filepath.Dir(path)] == pkgPath) {
+			diagnostics[path] = []lspDiagnostic{}
+		}
+	}
+	for path, diags := range diagnostics {
+		if len(diags) > 0 {
+			s.published[path] = true
+		} else {
+			delete(s.published, path)
+		}
+		s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+			"uri":         pathToURI(path),
+			"diagnostics": diags,
+		})
+	}
+}
+
+func (s *lspServer) notify(method string, params interface{}) {
+	if err := s.write(lspNotification{JSONRPC: "2.0", Method: method, Params: params}); err != nil {
+		logger.Errorf("failed to write message: %s", err)
+	}
+}
+
+// hover returns the errors of the function whose name is under the cursor, in
+// its declaration or in a call to it.
+func (s *lspServer) hover(path string, pos lspPosition) interface{} {
+	s.flush()
+	if s.result == nil {
+		return nil
+	}
+	src, err := s.app.readFile(path)
+	if err != nil {
+		return nil
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+	tf := fset.File(f.Pos())
+	if pos.Line < 0 || pos.Line >= tf.LineCount() {
+		return nil
+	}
+	lineStart := tf.Offset(tf.LineStart(pos.Line + 1))
+	offset := lineStart + utf16Offset(lineOf(src, pos.Line+1), pos.Character)
+	within := func(node ast.Node) bool {
+		return tf.Offset(node.Pos()) <= offset && offset <= tf.Offset(node.End())
+	}
+
+	var name *ast.Ident
+	var agError *errauditor.AggregatedError
+	ast.Inspect(f, func(node ast.Node) bool {
+		if node == nil || !within(node) {
+			return false
+		}
+		switch node := node.(type) {
+		case *ast.FuncDecl:
+			if within(node.Name) {
+				name, agError = node.Name, s.declErrors(path, fset.Position(node.Pos()))
+			}
+		case *ast.CallExpr:
+			// the innermost call under the cursor wins.
+			if ident := calledName(node.Fun); ident != nil && within(ident) {
+				name, agError = ident, nil
+				if d := s.result.Callee(fset.Position(node.Pos()), ident.Name); d != nil {
+					agError = s.funcErrors(d.Key())
+				}
+			}
+		}
+		return true
+	})
+	if agError == nil {
+		return nil
+	}
+
+	// the summary lists the errors returned through calls too, like the
+	// text report.
+	var summary []string
+	seen := make(map[string]bool)
+	for _, e := range agError.Traced() {
+		errors := []string{e.Error}
+		if e.Entry != nil {
+			errors = (&errauditor.AggregatedError{Errors: []*errauditor.ErrorEntry{e.Entry}}).ErrorStrings()
+		}
+		for _, v := range errors {
+			if !seen[v] {
+				seen[v] = true
+				summary = append(summary, v)
+			}
+		}
+	}
+	value := "may return: " + strings.Join(summary, ", ")
+	for _, e := range agError.Traces {
+		if len(e.Trace) < 2 {
+			continue
+		}
+		// errors returned through calls, with their trace.
+		hops := make([]string, len(e.Trace))
+		for i, hop := range e.Trace {
+			hops[i] = hop.String()
+		}
+		value += "\n- `" + e.Error + "` from `" + strings.Join(hops, " -> ") + "`"
+	}
+	start := fset.Position(name.Pos())
+	end := fset.Position(name.End())
+	return map[string]interface{}{
+		"contents": map[string]string{
+			"kind":  "markdown",
+			"value": value,
+		},
+		"range": lspRange{
+			Start: lspPosition{Line: start.Line - 1, Character: utf16Column(lineOf(src, start.Line), start.Column)},
+			End:   lspPosition{Line: end.Line - 1, Character: utf16Column(lineOf(src, end.Line), end.Column)},
+		},
+	}
+}
+
+// declErrors returns the errors of the function declared at posn in path.
+func (s *lspServer) declErrors(path string, posn token.Position) *errauditor.AggregatedError {
+	for _, agError := range s.result.AggregatedErrors {
+		filename, err := filepath.Abs(agError.Pos.Filename)
+		if err == nil && filename == path && agError.Pos.Line == posn.Line {
+			return agError
+		}
+	}
+	return nil
+}
+
+// funcErrors returns the errors of the function with the qualified name key.
+func (s *lspServer) funcErrors(key string) *errauditor.AggregatedError {
+	for _, agError := range s.result.AggregatedErrors {
+		if agError.Key() == key {
+			return agError
+		}
+	}
+	return nil
+}
+
+// calledName returns the name of the function or method a call expression
+// calls, such as `Find` in `u.repo.Find(id)`.
+func calledName(fun ast.Expr) *ast.Ident {
+	switch fun := ast.Unparen(fun).(type) {
+	case *ast.Ident:
+		return fun
+	case *ast.SelectorExpr:
+		return fun.Sel
+	case *ast.IndexExpr:
+		return calledName(fun.X)
+	case *ast.IndexListExpr:
+		return calledName(fun.X)
+	}
+	return nil
+}
+
+// lineOf returns the line of src with the given 1-based number, without its
+// line terminator.
+func lineOf(src []byte, line int) []byte {
+	for ; line > 1; line-- {
+		i := bytes.IndexByte(src, '\n')
+		if i < 0 {
+			return nil
+		}
+		src = src[i+1:]
+	}
+	if i := bytes.IndexByte(src, '\n'); i >= 0 {
+		src = src[:i]
+	}
+	return src
+}
+
+// utf16Offset returns the byte offset in line of an LSP character, which
+// counts UTF-16 code units.
+func utf16Offset(line []byte, character int) int {
+	offset := 0
+	for offset < len(line) && character > 0 {
+		r, size := utf8.DecodeRune(line[offset:])
+		character -= utf16.RuneLen(r)
+		offset += size
+	}
+	return offset
+}
+
+// utf16Column returns the LSP character of a 1-based byte column of line.
+func utf16Column(line []byte, column int) int {
+	character := 0
+	for offset := 0; offset < column-1 && offset < len(line); {
+		r, size := utf8.DecodeRune(line[offset:])
+		character += utf16.RuneLen(r)
+		offset += size
+	}
+	if column-1 > len(line) {
+		// positions past the end of the line, such as at EOF.
+		character += column - 1 - len(line)
+	}
+	return character
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+func pathToURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
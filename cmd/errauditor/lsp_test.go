@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thedhejavu/errauditor/errauditor"
+)
+
+// lspClient drives the server like an editor would. Messages from the server
+// are read in the background so that notifications never block it.
+type lspClient struct {
+	t   *testing.T
+	in  *io.PipeWriter
+	out chan map[string]interface{}
+	id  int
+}
+
+func newLSPClient(t *testing.T, in *io.PipeWriter, out io.Reader) *lspClient {
+	c := &lspClient{t: t, in: in, out: make(chan map[string]interface{}, 64)}
+	go func() {
+		defer close(c.out)
+		r := bufio.NewReader(out)
+		for {
+			header, err := textproto.NewReader(r).ReadMIMEHeader()
+			if err != nil {
+				return
+			}
+			length, err := strconv.Atoi(header.Get("Content-Length"))
+			if err != nil {
+				return
+			}
+			body := make([]byte, length)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return
+			}
+			var msg map[string]interface{}
+			if err := json.Unmarshal(body, &msg); err != nil {
+				return
+			}
+			c.out <- msg
+		}
+	}()
+	return c
+}
+
+func (c *lspClient) send(method string, id int, params interface{}) {
+	c.t.Helper()
+	msg := map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params}
+	if id > 0 {
+		msg["id"] = id
+	}
+	body, err := json.Marshal(msg)
+	require.NoError(c.t, err)
+	_, err = fmt.Fprintf(c.in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	require.NoError(c.t, err)
+}
+
+func (c *lspClient) request(method string, params interface{}) map[string]interface{} {
+	c.t.Helper()
+	c.id++
+	c.send(method, c.id, params)
+	for {
+		msg := c.receive()
+		if id, ok := msg["id"].(float64); ok && int(id) == c.id {
+			return msg
+		}
+	}
+}
+
+func (c *lspClient) receive() map[string]interface{} {
+	c.t.Helper()
+	msg, ok := <-c.out
+	require.True(c.t, ok, "server closed the connection")
+	return msg
+}
+
+func TestLSP(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod": "module example.com/project\n",
+		"usecase.go": `package project
+
+import "example.com/project/apperrors"
+
+func GetAddressByUser() error {
+	return apperrors.ErrRecordNotFound
+}
+`,
+		"apperrors/errors.go": "package apperrors\n\nimport \"errors\"\n\nvar ErrRecordNotFound = errors.New(\"record not found\")\n",
+	})
+	path := filepath.Join(dir, "usecase.go")
+	uri := pathToURI(path)
+
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	a := &app{auditor: errauditor.NewAuditor(), jobs: 2}
+	exit := make(chan int, 1)
+	go func() {
+		exit <- a.lsp(inR, outW)
+		outW.Close()
+	}()
+	c := newLSPClient(t, inW, outR)
+
+	resp := c.request("initialize", map[string]interface{}{"rootUri": pathToURI(dir)})
+	caps := resp["result"].(map[string]interface{})["capabilities"].(map[string]interface{})
+	require.Equal(t, true, caps["hoverProvider"])
+	c.send("initialized", 0, map[string]interface{}{})
+
+	resp = c.request("textDocument/hover", map[string]interface{}{
+		"textDocument": map[string]string{"uri": uri},
+		"position":     map[string]int{"line": 4, "character": 7},
+	})
+	contents := resp["result"].(map[string]interface{})["contents"].(map[string]interface{})
+	require.Equal(t, "may return: ErrRecordNotFound()", contents["value"])
+
+	// hovering an unsaved buffer reflects the edit.
+	c.send("textDocument/didChange", 0, map[string]interface{}{
+		"textDocument": map[string]string{"uri": uri},
+		"contentChanges": []map[string]string{{"text": `package project
+
+import "example.com/project/apperrors"
+
+func GetAddressByUser() error {
+	return apperrors.ErrUnauthorized
+}
+`}},
+	})
+	resp = c.request("textDocument/hover", map[string]interface{}{
+		"textDocument": map[string]string{"uri": uri},
+		"position":     map[string]int{"line": 4, "character": 7},
+	})
+	contents = resp["result"].(map[string]interface{})["contents"].(map[string]interface{})
+	require.Equal(t, "may return: ErrUnauthorized()", contents["value"])
+
+	resp = c.request("textDocument/hover", map[string]interface{}{
+		"textDocument": map[string]string{"uri": uri},
+		"position":     map[string]int{"line": 0, "character": 2},
+	})
+	require.Nil(t, resp["result"])
+
+	c.request("shutdown", nil)
+	c.send("exit", 0, nil)
+	require.Equal(t, 0, <-exit)
+}
+
+func TestLSPCallSite(t *testing.T) {
+	dir := t.TempDir()
+	handler := "package project\n\nfunc Handle() error {\n\t/* \U0001F642 \u00e9 */ return GetAddressByUser()\n}\n"
+	writeFiles(t, dir, map[string]string{
+		"go.mod": "module example.com/project\n",
+		"usecase.go": `package project
+
+import "example.com/project/apperrors"
+
+func GetAddressByUser() error {
+	return apperrors.ErrRecordNotFound
+}
+`,
+		"handler.go":          handler,
+		"find.go":             "package project\n\nimport \"errors\"\n\nfunc Find(id string) error {\n\tif id == \"\" {\n\t\treturn errors.New(\"empty id\")\n\t}\n\treturn GetAddressByUser()\n}\n",
+		"apperrors/errors.go": "package apperrors\n\nimport \"errors\"\n\nvar ErrRecordNotFound = errors.New(\"record not found\")\n",
+	})
+	uri := pathToURI(filepath.Join(dir, "handler.go"))
+
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	a := &app{auditor: errauditor.NewAuditor(), jobs: 2}
+	exit := make(chan int, 1)
+	go func() {
+		exit <- a.lsp(inR, outW)
+		outW.Close()
+	}()
+	c := newLSPClient(t, inW, outR)
+	c.request("initialize", map[string]interface{}{"rootUri": pathToURI(dir)})
+	c.send("initialized", 0, map[string]interface{}{})
+
+	// the call is after a character taking two UTF-16 code units.
+	line := strings.Split(handler, "\n")[3]
+	character := len(utf16.Encode([]rune(line[:strings.Index(line, "GetAddressByUser")])))
+	resp := c.request("textDocument/hover", map[string]interface{}{
+		"textDocument": map[string]string{"uri": uri},
+		"position":     map[string]int{"line": 3, "character": character + 3},
+	})
+	result := resp["result"].(map[string]interface{})
+	require.Equal(t, "may return: ErrRecordNotFound()", result["contents"].(map[string]interface{})["value"])
+	require.Equal(t, map[string]interface{}{
+		"start": map[string]interface{}{"line": float64(3), "character": float64(character)},
+		"end":   map[string]interface{}{"line": float64(3), "character": float64(character + len("GetAddressByUser"))},
+	}, result["range"])
+
+	// the summary lists the errors returned through calls.
+	resp = c.request("textDocument/hover", map[string]interface{}{
+		"textDocument": map[string]string{"uri": pathToURI(filepath.Join(dir, "find.go"))},
+		"position":     map[string]int{"line": 4, "character": 6},
+	})
+	result = resp["result"].(map[string]interface{})
+	value := result["contents"].(map[string]interface{})["value"].(string)
+	require.True(t, strings.HasPrefix(value, "may return: New(\"empty id\",), ErrRecordNotFound()\n- `ErrRecordNotFound()` from `project.Find ("), value)
+
+	// edits are not audited on every keystroke.
+	c.send("textDocument/didChange", 0, map[string]interface{}{
+		"textDocument":   map[string]string{"uri": uri},
+		"contentChanges": []map[string]string{{"text": "package project\n"}},
+	})
+	c.id++
+	c.send("workspace/unknown", c.id, nil)
+	for {
+		msg := c.receive()
+		require.NotEqual(t, "textDocument/publishDiagnostics", msg["method"])
+		if id, ok := msg["id"].(float64); ok && int(id) == c.id {
+			break
+		}
+	}
+
+	c.request("shutdown", nil)
+	c.send("exit", 0, nil)
+	require.Equal(t, 0, <-exit)
+}
+
+func TestLSPUTF16(t *testing.T) {
+	dir := t.TempDir()
+	handler := "package project\n\nimport \"example.com/project/apperrors\"\n\n/* \U0001F642 \u00e9 */ func Handle() error {\n\treturn apperrors.ErrRecordNotFound\n}\n"
+	writeFiles(t, dir, map[string]string{
+		"go.mod":              "module example.com/project\n",
+		"handler.go":          handler,
+		"apperrors/errors.go": "package apperrors\n\nimport \"errors\"\n\nvar ErrRecordNotFound = errors.New(\"record not found\")\n",
+	})
+	uri := pathToURI(filepath.Join(dir, "handler.go"))
+
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	a := &app{auditor: errauditor.NewAuditor(), jobs: 2}
+	exit := make(chan int, 1)
+	go func() {
+		exit <- a.lsp(inR, outW)
+		outW.Close()
+	}()
+	c := newLSPClient(t, inW, outR)
+	c.request("initialize", map[string]interface{}{"rootUri": pathToURI(dir)})
+	c.send("initialized", 0, map[string]interface{}{})
+
+	// the name is after a character taking two UTF-16 code units.
+	line := strings.Split(handler, "\n")[4]
+	character := len(utf16.Encode([]rune(line[:strings.Index(line, "Handle")])))
+	resp := c.request("textDocument/hover", map[string]interface{}{
+		"textDocument": map[string]string{"uri": uri},
+		"position":     map[string]int{"line": 4, "character": character + 3},
+	})
+	result := resp["result"].(map[string]interface{})
+	require.Equal(t, "may return: ErrRecordNotFound()", result["contents"].(map[string]interface{})["value"])
+	require.Equal(t, map[string]interface{}{
+		"start": map[string]interface{}{"line": float64(4), "character": float64(character)},
+		"end":   map[string]interface{}{"line": float64(4), "character": float64(character + len("Handle"))},
+	}, result["range"])
+
+	// edits are not audited on every keystroke.
+	c.send("textDocument/didChange", 0, map[string]interface{}{
+		"textDocument":   map[string]string{"uri": uri},
+		"contentChanges": []map[string]string{{"text": "package project\n"}},
+	})
+	c.id++
+	c.send("workspace/unknown", c.id, nil)
+	for {
+		msg := c.receive()
+		require.NotEqual(t, "textDocument/publishDiagnostics", msg["method"])
+		if id, ok := msg["id"].(float64); ok && int(id) == c.id {
+			break
+		}
+	}
+
+	c.request("shutdown", nil)
+	c.send("exit", 0, nil)
+	require.Equal(t, 0, <-exit)
+}
+
+func TestUTF16(t *testing.T) {
+	line := []byte("a\U0001F642\u00e9b")
+	require.Equal(t, 0, utf16Offset(line, 0))
+	require.Equal(t, 1, utf16Offset(line, 1))
+	require.Equal(t, 5, utf16Offset(line, 3))
+	require.Equal(t, 7, utf16Offset(line, 4))
+	require.Equal(t, 8, utf16Offset(line, 10))
+	require.Equal(t, 3, utf16Column(line, 6))
+	require.Equal(t, 5, utf16Column(line, 9))
+}
@@ -1,19 +1,21 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
 	"flag"
 	"fmt"
+	"go/ast"
 	"go/build"
 	"go/parser"
 	"go/token"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/sirupsen/logrus"
 	"github.com/thedhejavu/errauditor/errauditor"
@@ -21,18 +23,52 @@ import (
 
 var (
 	flagSet = flag.NewFlagSet("errauditor", flag.ContinueOnError)
-	logger  *logrus.Logger
+	logger  = logrus.New()
 )
 
 type app struct {
-	excludeDirs     []string
-	excludePatterns []*regexp.Regexp
+	excludeDirs      []string
+	excludePatterns  []*regexp.Regexp
+	format           string
+	output           string
+	baseline         string
+	writeBaseline    bool
+	newFromRev       string
+	newFromPatch     string
+	jobs             int
+	tags             string
+	useCache         bool
+	cache            *cache
+	config           string
+	includeGenerated bool
+	tests            bool
+	reportUnused     bool
+	constructors     []errauditor.Constructor
+	from             stringList
+	handling         bool
+	grpcMappings     stringList
+	errorTypes       bool
+	auditor          *errauditor.Auditor
+	// overlay holds the unsaved editor buffers by absolute path, it is only
+	// modified by the lsp server between audits.
+	overlay map[string][]byte
+}
+
+// stringList is a flag that may be repeated.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
 }
 
 func main() {
 
 	a := &app{}
-	logger := logrus.New()
 
 	lvl, err := logrus.ParseLevel("info")
 	logger.SetFormatter(&logrus.TextFormatter{})
@@ -41,25 +77,274 @@ func main() {
 	}
 
 	logger.SetLevel(lvl)
+
+	flagSet.StringVar(&a.format, "format", "text", "output format: text, html, markdown or json")
+	flagSet.StringVar(&a.output, "o", "", "write the report to `file` instead of stdout")
+	flagSet.StringVar(&a.baseline, "baseline", "", "suppress the findings recorded in the baseline `file`")
+	flagSet.BoolVar(&a.writeBaseline, "write-baseline", false, "record all current findings in the -baseline file")
+	flagSet.StringVar(&a.newFromRev, "new-from-rev", "", "only report functions and findings on lines changed since git `revision`")
+	flagSet.StringVar(&a.newFromPatch, "new-from-patch", "", "only report functions and findings on lines changed in the unified diff `file`")
+	flagSet.IntVar(&a.jobs, "j", runtime.NumCPU(), "number of packages audited in parallel")
+	flagSet.StringVar(&a.tags, "tags", "", "comma-separated list of build `tags`")
+	flagSet.BoolVar(&a.useCache, "cache", true, "reuse the results of unchanged packages from the user cache directory")
+	flagSet.BoolVar(&a.includeGenerated, "include-generated", false, "audit generated files, findings inside them are still suppressed")
+	flagSet.BoolVar(&a.tests, "tests", false, "audit _test.go files of in-package and external test packages")
+	flagSet.BoolVar(&a.reportUnused, "report-unused-ignores", false, "report //errauditor:ignore directives that suppress no finding")
+	flagSet.Var(&a.from, "from", "only report the entry point `pkg.Func` and the functions it reaches, may be repeated")
+	flagSet.BoolVar(&a.handling, "handling", false, "report which errors of the called functions each call site checks")
+	flagSet.BoolVar(&a.errorTypes, "error-types", false, "report the types implementing error, their methods and the functions returning them")
+	flagSet.Var(&a.grpcMappings, "grpc-mapping", "translate domain errors to gRPC codes with the function `pkg.Func` in the grpc command, may be repeated")
+	flagSet.StringVar(&a.config, "config", "", "read settings from the JSON config `file`, also used by the golangci-lint plugin")
+	if err := flagSet.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+	if a.config != "" {
+		if err := a.loadConfig(); err != nil {
+			logger.Errorf("failed to read config: %s", err)
+			os.Exit(2)
+		}
+	}
+	if a.tags != "" {
+		build.Default.BuildTags = strings.Split(a.tags, ",")
+	}
+	a.auditor = a.newAuditor()
 	os.Exit(a.run(flagSet.Args()))
 }
 
+// loadConfig applies the settings of the -config file. Flags given on the
+// command line take precedence.
+func (a *app) loadConfig() error {
+	f, err := os.Open(a.config)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cfg, err := errauditor.ReadConfig(f)
+	if err != nil {
+		return err
+	}
+	a.excludeDirs = append(a.excludeDirs, cfg.Exclude...)
+	if a.baseline == "" {
+		a.baseline = cfg.Baseline
+	}
+	a.includeGenerated = a.includeGenerated || cfg.IncludeGenerated
+	a.tests = a.tests || cfg.Tests
+	a.reportUnused = a.reportUnused || cfg.ReportUnusedIgnores
+	if _, err := errauditor.NewRegistry(cfg.Constructors); err != nil {
+		return err
+	}
+	a.constructors = cfg.Constructors
+	return nil
+}
+
+// newAuditor returns an auditor knowing the constructors of the config file.
+func (a *app) newAuditor() *errauditor.Auditor {
+	auditor := errauditor.NewAuditor()
+	if err := auditor.Register(a.constructors...); err != nil {
+		// the constructors were validated with the config.
+		logger.Errorf("failed to register constructors: %s", err)
+	}
+	return auditor
+}
+
 func (a *app) run(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "diff":
+			return a.diff(args[1:])
+		case "watch":
+			return a.watch(args[1:])
+		case "openapi":
+			return a.openAPI(args[1:])
+		case "grpc":
+			return a.grpc(args[1:])
+		case "lsp":
+			return a.lsp(os.Stdin, os.Stdout)
+		}
+	}
+	if a.writeBaseline && a.baseline == "" {
+		logger.Errorf("-write-baseline requires -baseline")
+		return 2
+	}
 	err := a.check(args)
 	if err != nil {
 		logger.Errorf("failed to run with: %s", err)
 		return 1
 	}
+	result := a.auditor.Result()
+	if a.reportUnused {
+		result.ReportUnusedIgnores()
+	}
+	// handlings and error types need all the declarations, Scope keeps those
+	// of the reachable functions.
+	if a.handling {
+		result.Handlings = result.ErrorHandling()
+	}
+	if a.errorTypes {
+		result.ErrorTypes = result.ErrorTypeHierarchy()
+	}
+	if len(a.from) > 0 {
+		if err := result.Scope(a.from...); err != nil {
+			logger.Errorf("failed to scope the audit: %s", err)
+			return 1
+		}
+	}
+	if a.newFromRev != "" || a.newFromPatch != "" {
+		err = a.filterChanges(result)
+		if err != nil {
+			logger.Errorf("failed to read changes: %s", err)
+			return 1
+		}
+	}
+	if a.baseline != "" {
+		if a.writeBaseline {
+			err = a.saveBaseline(result)
+			if err != nil {
+				logger.Errorf("failed to write baseline: %s", err)
+				return 1
+			}
+			return 0
+		}
+		err = a.applyBaseline(result)
+		if err != nil {
+			logger.Errorf("failed to read baseline: %s", err)
+			return 1
+		}
+	}
+	err = a.report(result)
+	if err != nil {
+		logger.Errorf("failed to write report: %s", err)
+		return 1
+	}
 	return 0
 }
 
+func (a *app) saveBaseline(result *errauditor.Result) (err error) {
+	f, err := os.Create(a.baseline)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	return errauditor.WriteBaseline(f, errauditor.NewBaseline(result))
+}
+
+func (a *app) applyBaseline(result *errauditor.Result) error {
+	f, err := os.Open(a.baseline)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	baseline, err := errauditor.ReadBaseline(f)
+	if err != nil {
+		return err
+	}
+	baseline.Filter(result)
+	return nil
+}
+
+// filterChanges restricts the result to the lines changed since -new-from-rev
+// or in -new-from-patch. The whole packages are still audited beforehand.
+func (a *app) filterChanges(result *errauditor.Result) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	var patch io.Reader
+	if a.newFromRev != "" {
+		out, err := git("diff", "--relative", "--no-color", "--no-ext-diff", "-U0", a.newFromRev, "--")
+		if err != nil {
+			return err
+		}
+		patch = strings.NewReader(out)
+	} else {
+		f, err := os.Open(a.newFromPatch)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		patch = f
+	}
+
+	changes, err := errauditor.ParsePatch(patch, wd)
+	if err != nil {
+		return err
+	}
+	changes.Filter(result)
+	return nil
+}
+
+func (a *app) report(result *errauditor.Result) (err error) {
+	var write func(io.Writer, *errauditor.Result) error
+	switch a.format {
+	case "text":
+		write = errauditor.WriteText
+	case "html":
+		write = errauditor.WriteHTML
+	case "markdown":
+		write = errauditor.WriteMarkdown
+	case "json":
+		write = errauditor.WriteJSON
+	default:
+		return fmt.Errorf("unknown format %q", a.format)
+	}
+
+	w := io.Writer(os.Stdout)
+	if a.output != "" {
+		f, err := os.Create(a.output)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}()
+		w = f
+	}
+	return write(w, result)
+}
+
 func (a *app) check(args []string) error {
+	files, packages, err := a.load(args)
+	if err != nil {
+		return err
+	}
+
+	// files and packages are audited by a pool of workers, the auditor
+	// aggregates their results.
+	a.parallel(len(files), func(i int) {
+		err := a.checkFile(a.auditor, token.NewFileSet(), importPathForDir(filepath.Dir(files[i])), files[i])
+		if err != nil {
+			logger.Debugf("failed to checkFile: %s", err)
+		}
+	})
+
+	if a.useCache {
+		a.cache = newCache(packages, a.files, a.imports, a.cacheOptions())
+	}
+	a.parallel(len(packages), func(i int) {
+		err := a.checkImportedPackage(packages[i])
+		if err != nil {
+			logger.Debugf("failed to checkImportedPackage: %s", err)
+		}
+	})
+	return nil
+}
+
+// load resolves the arguments to the files and packages to audit.
+func (a *app) load(args []string) ([]string, []*build.Package, error) {
 	// exclude directories or files
 	a.excludePatterns = make([]*regexp.Regexp, 0, len(a.excludeDirs))
 	for _, d := range a.excludeDirs {
 		p, err := regexp.Compile(d)
 		if err != nil {
-			return fmt.Errorf("failed to parse exclude dir pattern: %v", err)
+			return nil, nil, fmt.Errorf("failed to parse exclude dir pattern: %v", err)
 		}
 		a.excludePatterns = append(a.excludePatterns, p)
 	}
@@ -82,31 +367,58 @@ func (a *app) check(args []string) error {
 		}
 	}
 
-	for _, f := range files {
-		err := a.checkFile(f)
-		if err != nil {
-			logger.Debugf("failed to checkFile: %s", err)
-			continue
+	imported := make([]*build.Package, len(dirs)+len(pkgs))
+	a.parallel(len(imported), func(i int) {
+		var err error
+		if i < len(dirs) {
+			imported[i], err = a.checkDir(dirs[i])
+			if err != nil {
+				logger.Debugf("failed to checkDir: %s", err)
+			}
+		} else {
+			imported[i], err = a.checkPackage(pkgs[i-len(dirs)])
+			if err != nil {
+				logger.Debugf("failed to checkPackage: %s", err)
+			}
 		}
-	}
-	for _, d := range dirs {
-		err := a.checkDir(d)
-		if err != nil {
-			logger.Debugf("failed to checkDir: %s", err)
-			continue
+	})
+	packages := imported[:0]
+	for _, pkg := range imported {
+		if pkg != nil {
+			packages = append(packages, pkg)
 		}
 	}
-	for _, p := range pkgs {
-		err := a.checkPackage(p)
-		if err != nil {
-			logger.Debugf("failed to checkPackage: %s", err)
-			continue
-		}
+	return files, packages, nil
+}
+
+// parallel calls fn for every index in [0, n) on a pool of -j workers.
+func (a *app) parallel(n int, fn func(i int)) {
+	workers := a.jobs
+	if workers < 1 {
+		workers = 1
 	}
-	return nil
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
 }
 
-func (a *app) checkFile(path string) error {
+func (a *app) checkFile(auditor *errauditor.Auditor, fset *token.FileSet, pkgPath, path string) error {
+	if !a.tests && strings.HasSuffix(path, "_test.go") {
+		return nil
+	}
 	dir := filepath.Dir(path)
 	for _, p := range a.excludePatterns {
 		if p.MatchString(dir) {
@@ -114,20 +426,34 @@ func (a *app) checkFile(path string) error {
 		}
 	}
 
-	src, err := ioutil.ReadFile(path)
+	src, err := a.readFile(path)
 	if err != nil {
 		return nil
 	}
-	fset := token.NewFileSet()
 	f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
 	if err != nil {
 		return nil
 	}
-	if len(f.Comments) > 0 && isGenerated(src) {
+	// generated files are only audited on request, their functions are then
+	// reported but the auditor suppresses findings inside them.
+	if !a.includeGenerated && ast.IsGenerated(f) {
 		return fmt.Errorf("%s is a generated file", path)
 	}
 
-	return errauditor.Run(f, fset)
+	return auditor.Run(pkgPath, f, fset)
+}
+
+// readFile returns the unsaved contents of an editor buffer for path, or the
+// contents on disk.
+func (a *app) readFile(path string) ([]byte, error) {
+	if len(a.overlay) > 0 {
+		if abs, err := filepath.Abs(path); err == nil {
+			if src, ok := a.overlay[abs]; ok {
+				return src, nil
+			}
+		}
+	}
+	return ioutil.ReadFile(path)
 }
 
 // Copyright (c) 2013 The Go Authors. All rights reserved.
@@ -135,53 +461,101 @@ func (a *app) checkFile(path string) error {
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file or at
 // https://developers.google.com/open-source/licenses/bsd.
-func (a *app) checkDir(dirname string) error {
+func (a *app) checkDir(dirname string) (*build.Package, error) {
 	for _, p := range a.excludePatterns {
 		if p.MatchString(dirname) {
-			return nil
+			return nil, nil
 		}
 	}
 	pkg, err := build.ImportDir(dirname, 0)
 	if err != nil {
 		if _, nogo := err.(*build.NoGoError); nogo {
 			// Don't complain if the failure is due to no Go source files.
-			return nil
+			return nil, nil
 		}
-		return nil
+		return nil, nil
 	}
-	return a.checkImportedPackage(pkg)
+	return pkg, nil
 }
 
-func (a *app) checkPackage(pkgname string) error {
+func (a *app) checkPackage(pkgname string) (*build.Package, error) {
 	pkg, err := build.Import(pkgname, ".", 0)
 	if err != nil {
 		if _, nogo := err.(*build.NoGoError); nogo {
 			// Don't complain if the failure is due to no Go source files.
-			return nil
+			return nil, nil
 		}
-		return nil
+		return nil, nil
 	}
 
-	return a.checkImportedPackage(pkg)
+	return pkg, nil
 }
 
-func (a *app) checkImportedPackage(pkg *build.Package) (err error) {
+// cacheOptions identifies the flags that change the result of a package.
+func (a *app) cacheOptions() string {
+	return fmt.Sprintf("include-generated=%t tests=%t constructors=%v", a.includeGenerated, a.tests, a.constructors)
+}
+
+// files returns the source files of pkg that are audited.
+func (a *app) files(pkg *build.Package) []string {
 	var files []string
 	files = append(files, pkg.GoFiles...)
 	files = append(files, pkg.CgoFiles...)
-	files = append(files, pkg.TestGoFiles...)
+	if a.tests {
+		files = append(files, pkg.TestGoFiles...)
+		files = append(files, pkg.XTestGoFiles...)
+	}
+	return files
+}
 
+// imports returns the import paths of the audited files of pkg.
+func (a *app) imports(pkg *build.Package) []string {
+	imports := append([]string(nil), pkg.Imports...)
+	if a.tests {
+		imports = append(imports, pkg.TestImports...)
+		imports = append(imports, pkg.XTestImports...)
+	}
+	return imports
+}
+
+func (a *app) checkImportedPackage(pkg *build.Package) (err error) {
+	a.auditor.Merge(a.auditPackage(pkg))
+	return
+}
+
+// auditPackage returns the result of a single package, from the cache when
+// its files did not change.
+func (a *app) auditPackage(pkg *build.Package) *errauditor.Result {
+	pkgPath := importPathForDir(pkg.Dir)
+	if result, ok := a.cache.get(pkgPath); ok {
+		return result
+	}
+
+	// files of a package share a file set.
+	fset := token.NewFileSet()
+	auditor := a.newAuditor()
+	xtests := make(map[string]bool, len(pkg.XTestGoFiles))
+	for _, f := range pkg.XTestGoFiles {
+		xtests[f] = true
+	}
 	// TODO: Reduce allocation.
 	if pkg.Dir != "." {
-		for _, f := range files {
-			err := a.checkFile(filepath.Join(pkg.Dir, f))
+		for _, f := range a.files(pkg) {
+			path := pkgPath
+			if xtests[f] {
+				// external tests form a package of their own.
+				path += "_test"
+			}
+			err := a.checkFile(auditor, fset, path, filepath.Join(pkg.Dir, f))
 			if err != nil {
 				logger.Debugf("failed to checkImportedPackage: %s", err)
 				continue
 			}
 		}
 	}
-	return
+	result := auditor.Raw()
+	a.cache.put(pkgPath, result)
+	return result
 }
 
 func isDir(filename string) bool {
@@ -193,20 +567,3 @@ func exists(filename string) bool {
 	_, err := os.Stat(filename)
 	return err == nil
 }
-
-// isGenerated reports whether the source file is generated code
-// according the rules from https://golang.org/s/generatedcode.
-func isGenerated(src []byte) bool {
-	var (
-		genHdr = []byte("// Code generated ")
-		genFtr = []byte(" DO NOT EDIT.")
-	)
-	sc := bufio.NewScanner(bytes.NewReader(src))
-	for sc.Scan() {
-		b := sc.Bytes()
-		if bytes.HasPrefix(b, genHdr) && bytes.HasSuffix(b, genFtr) && len(b) >= len(genHdr)+len(genFtr) {
-			return true
-		}
-	}
-	return false
-}
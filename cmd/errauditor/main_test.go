@@ -0,0 +1,66 @@
+package main
+
+import (
+	"go/build"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/thedhejavu/errauditor/errauditor"
+)
+
+func TestAuditPackageTests(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":                   "module example.com/project\n",
+		"usecase.go":               "package project\n\nimport \"errors\"\n\nfunc Get() error {\n\treturn errors.New(\"get\")\n}\n",
+		"usecase_internal_test.go": "package project\n\nimport \"errors\"\n\nfunc helper() error {\n\treturn errors.New(\"helper\")\n}\n",
+		"usecase_test.go":          "package project_test\n\nimport \"errors\"\n\nfunc fixture() error {\n\treturn errors.New(\"fixture\")\n}\n",
+	})
+	pkg, err := build.ImportDir(filepath.Join(dir), 0)
+	require.NoError(t, err)
+
+	funcs := func(a *app) map[string]*errauditor.AggregatedError {
+		m := make(map[string]*errauditor.AggregatedError)
+		for _, agError := range a.auditPackage(pkg).AggregatedErrors {
+			m[agError.Package+"."+agError.Func] = agError
+		}
+		return m
+	}
+
+	withoutTests := funcs(&app{})
+	require.Len(t, withoutTests, 1)
+	require.Contains(t, withoutTests, "example.com/project.Get")
+
+	withTests := funcs(&app{tests: true})
+	require.Len(t, withTests, 3)
+	require.False(t, withTests["example.com/project.Get"].Test)
+	require.True(t, withTests["example.com/project.helper"].Test)
+	require.True(t, withTests["example.com/project_test.fixture"].Test)
+}
+
+func TestAuditPackageGenerated(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":       "module example.com/project\n",
+		"usecase.go":   "package project\n\nimport \"errors\"\n\nfunc Get() error {\n\treturn errors.New(\"get\")\n}\n",
+		"mock.go":      "// Code generated by mockgen. DO NOT EDIT.\n\npackage project\n\nimport \"errors\"\n\nfunc Mock() error {\n\treturn errors.New(\"mock\")\n}\n",
+		"late_note.go": "package project\n\n// Code generated by hand. DO NOT EDIT.\n\nimport \"errors\"\n\nfunc Late() error {\n\treturn errors.New(\"late\")\n}\n",
+	})
+	pkg, err := build.ImportDir(filepath.Join(dir), 0)
+	require.NoError(t, err)
+
+	funcs := func(a *app) []string {
+		var names []string
+		for _, agError := range a.auditPackage(pkg).AggregatedErrors {
+			names = append(names, agError.Func)
+		}
+		sort.Strings(names)
+		return names
+	}
+	// a header after the package clause does not mark the file as generated,
+	// like for the auditor.
+	require.Equal(t, []string{"Get", "Late"}, funcs(&app{}))
+	require.Equal(t, []string{"Get", "Late", "Mock"}, funcs(&app{includeGenerated: true}))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// importPathForDir returns the import path of the package in dir by locating
+// the enclosing go.mod. go/build reports "." for directories outside of GOPATH,
+// which is not usable to tell packages apart. The directory itself is returned
+// when no module is found.
+func importPathForDir(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return filepath.ToSlash(filepath.Clean(dir))
+	}
+	for d := abs; ; d = filepath.Dir(d) {
+		if modPath := modulePath(filepath.Join(d, "go.mod")); modPath != "" {
+			rel, err := filepath.Rel(d, abs)
+			if err != nil || rel == "." {
+				return modPath
+			}
+			return path.Join(modPath, filepath.ToSlash(rel))
+		}
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(dir))
+}
+
+// modulePath returns the module path declared in the go.mod file at gomod.
+func modulePath(gomod string) string {
+	data, err := ioutil.ReadFile(gomod)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Debugf("failed to read %s: %s", gomod, err)
+		}
+		return ""
+	}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(line, "module") {
+			return strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module")), `"`)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/thedhejavu/errauditor/errauditor"
+)
+
+// openAPI audits the packages and writes the error responses of the
+// operations registered by a router as an OpenAPI fragment.
+func (a *app) openAPI(args []string) int {
+	if len(args) < 1 {
+		logger.Errorf("usage: errauditor openapi <router> [packages]")
+		return 2
+	}
+	if err := a.check(args[1:]); err != nil {
+		logger.Errorf("failed to run with: %s", err)
+		return 1
+	}
+	doc, err := a.auditor.Result().OpenAPI(args[0])
+	if err != nil {
+		logger.Errorf("failed to generate responses: %s", err)
+		return 1
+	}
+
+	w := io.Writer(os.Stdout)
+	var f *os.File
+	if a.output != "" {
+		f, err = os.Create(a.output)
+		if err != nil {
+			logger.Errorf("failed to write responses: %s", err)
+			return 1
+		}
+		w = f
+	}
+	err = errauditor.WriteOpenAPI(w, doc)
+	// the file is closed explicitly, a failed flush truncates it.
+	if f != nil {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		logger.Errorf("failed to write responses: %s", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/thedhejavu/errauditor/errauditor"
+)
+
+// watchDelay is how long the watcher waits for more file events before
+// auditing again, so that a save touching several files triggers one audit.
+const watchDelay = 200 * time.Millisecond
+
+// watch audits the packages once, then re-audits packages whose files are
+// saved and prints only the functions whose error set changed.
+func (a *app) watch(args []string) int {
+	ws, err := newWorkspace(a, args)
+	if err != nil {
+		logger.Errorf("failed to run with: %s", err)
+		return 1
+	}
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Errorf("failed to watch: %s", err)
+		return 1
+	}
+	defer fsw.Close()
+	for dir := range ws.dirs {
+		if err := fsw.Add(dir); err != nil {
+			logger.Errorf("failed to watch %s: %s", dir, err)
+			return 1
+		}
+	}
+
+	current := ws.snapshot()
+	if err := errauditor.WriteText(os.Stdout, current); err != nil {
+		logger.Errorf("failed to write report: %s", err)
+		return 1
+	}
+	logger.Infof("watching %d packages for changes", len(ws.packages))
+
+	changed := make(map[string]bool)
+	timer := time.NewTimer(watchDelay)
+	timer.Stop()
+	for {
+		select {
+		case event, ok := <-fsw.Events:
+			if !ok {
+				return 0
+			}
+			if !strings.HasSuffix(event.Name, ".go") || event.Op == fsnotify.Chmod {
+				continue
+			}
+			if pkgPath, ok := ws.dirs[filepath.Dir(event.Name)]; ok {
+				changed[pkgPath] = true
+				timer.Reset(watchDelay)
+			}
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return 0
+			}
+			logger.Errorf("watch error: %s", err)
+		case <-timer.C:
+			for pkgPath := range changed {
+				ws.reload(pkgPath)
+				delete(changed, pkgPath)
+			}
+			next := ws.snapshot()
+			diff := &errauditor.Result{AggregatedErrors: errauditor.ChangedFuncs(current, next)}
+			current = next
+			if len(diff.AggregatedErrors) == 0 {
+				continue
+			}
+			if err := errauditor.WriteText(os.Stdout, diff); err != nil {
+				logger.Errorf("failed to write report: %s", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"go/build"
+	"path/filepath"
+
+	"github.com/thedhejavu/errauditor/errauditor"
+)
+
+// workspace keeps the results of a set of packages in memory so that a single
+// package can be audited again without auditing the others.
+type workspace struct {
+	app      *app
+	packages map[string]*build.Package
+	results  map[string]*errauditor.Result
+	// dirs maps the absolute directory of each package to its import path.
+	dirs map[string]string
+}
+
+// newWorkspace audits the packages matched by args.
+func newWorkspace(a *app, args []string) (*workspace, error) {
+	_, packages, err := a.load(args)
+	if err != nil {
+		return nil, err
+	}
+	if a.useCache {
+		a.cache = newCache(packages, a.files, a.imports, a.cacheOptions())
+	}
+
+	ws := &workspace{
+		app:      a,
+		packages: make(map[string]*build.Package, len(packages)),
+		results:  make(map[string]*errauditor.Result, len(packages)),
+		dirs:     make(map[string]string, len(packages)),
+	}
+	results := make([]*errauditor.Result, len(packages))
+	a.parallel(len(packages), func(i int) {
+		results[i] = a.auditPackage(packages[i])
+	})
+	for i, pkg := range packages {
+		if err := ws.add(pkg, results[i]); err != nil {
+			return nil, err
+		}
+	}
+	// the cache keys were computed for the files at startup.
+	a.cache = nil
+	return ws, nil
+}
+
+func (ws *workspace) add(pkg *build.Package, result *errauditor.Result) error {
+	dir, err := filepath.Abs(pkg.Dir)
+	if err != nil {
+		return err
+	}
+	pkgPath := importPathForDir(pkg.Dir)
+	ws.packages[pkgPath] = pkg
+	ws.results[pkgPath] = result
+	ws.dirs[dir] = pkgPath
+	return nil
+}
+
+// packageOf returns the import path of the package in the absolute directory
+// dir, loading the package when it is not part of the workspace yet.
+func (ws *workspace) packageOf(dir string) (string, bool) {
+	if pkgPath, ok := ws.dirs[dir]; ok {
+		return pkgPath, true
+	}
+	pkg, err := ws.app.checkDir(dir)
+	if err != nil || pkg == nil {
+		return "", false
+	}
+	if err := ws.add(pkg, ws.app.auditPackage(pkg)); err != nil {
+		return "", false
+	}
+	return ws.dirs[dir], true
+}
+
+// reload imports the package again, since files may have been added or
+// removed, and audits it.
+func (ws *workspace) reload(pkgPath string) {
+	pkg, err := ws.app.checkDir(ws.packages[pkgPath].Dir)
+	if err != nil || pkg == nil {
+		logger.Debugf("failed to reload %s: %v", pkgPath, err)
+		delete(ws.results, pkgPath)
+		return
+	}
+	ws.packages[pkgPath] = pkg
+	ws.results[pkgPath] = ws.app.auditPackage(pkg)
+}
+
+// snapshot merges the results of all packages.
+func (ws *workspace) snapshot() *errauditor.Result {
+	auditor := errauditor.NewAuditor()
+	for _, result := range ws.results {
+		auditor.Merge(result)
+	}
+	result := auditor.Result()
+	if ws.app.reportUnused {
+		result.ReportUnusedIgnores()
+	}
+	return result
+}
@@ -0,0 +1,93 @@
+package errauditor
+
+import (
+	"go/ast"
+	"go/token"
+	"sync"
+)
+
+// Auditor aggregates the results of audited files. It is safe for concurrent
+// use, so files and packages can be audited in parallel.
+type Auditor struct {
+	mu       sync.Mutex
+	result   Result
+	registry Registry
+}
+
+// NewAuditor returns an auditor with an empty result.
+func NewAuditor() *Auditor {
+	return &Auditor{}
+}
+
+// Register declares the error constructors whose calls are reported in their
+// structured form by the following runs.
+func (a *Auditor) Register(constructors ...Constructor) error {
+	registry, err := NewRegistry(constructors)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// runs in progress keep reading the previous registry.
+	for name, c := range a.registry {
+		if _, ok := registry[name]; !ok {
+			registry[name] = c
+		}
+	}
+	a.registry = registry
+	return nil
+}
+
+// Run audits a single file of package pkgPath.
+func (a *Auditor) Run(pkgPath string, f *ast.File, fset *token.FileSet) error {
+	a.mu.Lock()
+	registry := a.registry
+	a.mu.Unlock()
+	result := walkFile(pkgPath, f, fset, registry)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.result.Merge(result)
+	return nil
+}
+
+// Merge adds a previously computed result, such as one restored from a cache.
+func (a *Auditor) Merge(result *Result) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.result.Merge(result)
+}
+
+// Raw returns a copy of the results of the files audited so far, before the
+// rules run and findings are suppressed. It is meant to be cached or merged
+// into another auditor.
+func (a *Auditor) Raw() *Result {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	result := &Result{}
+	result.Merge(&a.result)
+	return result
+}
+
+// Result returns a sorted copy of the result aggregated so far with the
+// traces of the errors and the findings of the rules, without the findings
+// inside generated files or suppressed by ignore directives.
+func (a *Auditor) Result() *Result {
+	result := a.Raw()
+	result.traceAggregatedErrors()
+	result.checkHTTPStatuses()
+	result.checkErrorHandling()
+	result.checkErrorTypes()
+	result.checkIgnores()
+	// directives are marked as used, so they are copied too.
+	for i, ig := range result.Ignores {
+		copied := *ig
+		result.Ignores[i] = &copied
+	}
+	result.applyIgnores()
+	result.suppressGenerated()
+	result.Sort()
+	return result
+}
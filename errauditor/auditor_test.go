@@ -0,0 +1,55 @@
+package errauditor
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditorConcurrentRun(t *testing.T) {
+	auditor := NewAuditor()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		pkgPath := fmt.Sprintf("example.com/project%d", i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, "usecase.go", usecaseSrc, parser.ParseComments)
+			require.NoError(t, err)
+			require.NoError(t, auditor.Run(pkgPath, f, fset))
+		}()
+	}
+	wg.Wait()
+
+	result := auditor.Result()
+	require.Len(t, result.AggregatedErrors, 8)
+	for i, agError := range result.AggregatedErrors {
+		require.Equal(t, fmt.Sprintf("example.com/project%d", i), agError.Package)
+	}
+	require.EqualValues(t, 8, result.WrappedErrorCount)
+	require.EqualValues(t, 8, result.ConstErrorCount)
+}
+
+func TestAuditorGeneratedFiles(t *testing.T) {
+	auditor := NewAuditor()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "usecase.pb.go", "// Code generated by protoc-gen-go. DO NOT EDIT.\n\n"+usecaseSrc, parser.ParseComments)
+	require.NoError(t, err)
+	require.NoError(t, auditor.Run("example.com/project", f, fset))
+	auditor.Merge(&Result{Findings: []*Finding{
+		{Rule: "example", Pos: token.Position{Filename: "usecase.pb.go", Line: 14}},
+		{Rule: "example", Pos: token.Position{Filename: "usecase.go", Line: 14}},
+	}})
+
+	result := auditor.Result()
+	require.Len(t, result.AggregatedErrors, 1)
+	require.True(t, result.AggregatedErrors[0].Generated)
+	require.Len(t, result.Findings, 1)
+	require.Equal(t, "usecase.go", result.Findings[0].Pos.Filename)
+}
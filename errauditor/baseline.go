@@ -0,0 +1,96 @@
+package errauditor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// BaselineEntry identifies a recorded finding. It deliberately leaves out the
+// position so that entries survive unrelated edits to the file.
+type BaselineEntry struct {
+	Package     string `json:"package"`
+	Func        string `json:"func"`
+	Rule        string `json:"rule"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// Baseline is a set of known findings that are not reported again.
+type Baseline struct {
+	Entries []BaselineEntry `json:"entries"`
+}
+
+// Fingerprint identifies the finding within its function by rule and message.
+func (f *Finding) Fingerprint() string {
+	sum := sha256.Sum256([]byte(f.Rule + "\x00" + f.Message))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (f *Finding) baselineEntry() BaselineEntry {
+	return BaselineEntry{
+		Package:     f.Package,
+		Func:        f.Func,
+		Rule:        f.Rule,
+		Fingerprint: f.Fingerprint(),
+	}
+}
+
+// NewBaseline records all findings of the result.
+func NewBaseline(r *Result) *Baseline {
+	b := &Baseline{Entries: make([]BaselineEntry, 0, len(r.Findings))}
+	for _, finding := range r.Findings {
+		b.Entries = append(b.Entries, finding.baselineEntry())
+	}
+	sort.Slice(b.Entries, func(i, j int) bool {
+		x, y := b.Entries[i], b.Entries[j]
+		if x.Package != y.Package {
+			return x.Package < y.Package
+		}
+		if x.Func != y.Func {
+			return x.Func < y.Func
+		}
+		if x.Rule != y.Rule {
+			return x.Rule < y.Rule
+		}
+		return x.Fingerprint < y.Fingerprint
+	})
+	return b
+}
+
+// ReadBaseline decodes a baseline previously written with WriteBaseline.
+func ReadBaseline(r io.Reader) (*Baseline, error) {
+	var b Baseline
+	if err := json.NewDecoder(r).Decode(&b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// WriteBaseline encodes the baseline as indented JSON.
+func WriteBaseline(w io.Writer, b *Baseline) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(b)
+}
+
+// Filter removes the findings recorded in the baseline from the result. Each
+// entry suppresses a single finding, so a function that gains another
+// occurrence of an already known finding still reports the new one.
+func (b *Baseline) Filter(r *Result) {
+	known := make(map[BaselineEntry]int, len(b.Entries))
+	for _, entry := range b.Entries {
+		known[entry]++
+	}
+	findings := r.Findings[:0]
+	for _, finding := range r.Findings {
+		entry := finding.baselineEntry()
+		if known[entry] > 0 {
+			known[entry]--
+			continue
+		}
+		findings = append(findings, finding)
+	}
+	r.Findings = findings
+}
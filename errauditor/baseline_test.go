@@ -0,0 +1,45 @@
+package errauditor
+
+import (
+	"bytes"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBaseline(t *testing.T) {
+	finding := func(fn string, line int, message string) *Finding {
+		return &Finding{
+			Rule:    "example",
+			Package: "example.com/project",
+			Func:    fn,
+			Pos:     token.Position{Filename: "usecase.go", Line: line},
+			Message: message,
+		}
+	}
+
+	old := &Result{Findings: []*Finding{
+		finding("GetAddressByUser", 10, "first"),
+		finding("GetDrilldown", 20, "second"),
+	}}
+	var buf bytes.Buffer
+	require.NoError(t, WriteBaseline(&buf, NewBaseline(old)))
+
+	baseline, err := ReadBaseline(&buf)
+	require.NoError(t, err)
+	require.Len(t, baseline.Entries, 2)
+
+	// the same findings moved by unrelated edits, plus new ones.
+	current := &Result{Findings: []*Finding{
+		finding("GetAddressByUser", 14, "first"),
+		finding("GetAddressByUser", 18, "first"),
+		finding("GetDrilldown", 24, "second"),
+		finding("GetDrilldowns", 30, "second"),
+	}}
+	baseline.Filter(current)
+
+	require.Len(t, current.Findings, 2)
+	require.Equal(t, 18, current.Findings[0].Pos.Line)
+	require.Equal(t, "GetDrilldowns", current.Findings[1].Func)
+}
@@ -0,0 +1,500 @@
+package errauditor
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strings"
+)
+
+// Decl is a function or a package level variable of an audited package with
+// the calls and references it makes.
+type Decl struct {
+	Package string
+	Recv    string
+	Name    string
+	Pos     token.Position
+	End     token.Position
+	// Handler is set for functions taking an HTTP request or the context of a
+	// web framework.
+	Handler bool
+	// RPC is set for methods with the signature of a gRPC service method.
+	RPC bool
+	// Error is set for functions returning an error.
+	Error bool
+	// Type is the qualified type of the first result of a function or of a
+	// variable, such as `*example.com/project/pkg/apperrors.DomainError`,
+	// empty when it is not declared.
+	Type string
+	// Var is set for package level variables.
+	Var   bool
+	Calls []*Call
+	// Routes holds the HTTP routes registered by a function.
+	Routes []*Route
+}
+
+// Key returns the qualified name of the declaration, such as
+// `example.com/project.(*usecase).Get`.
+func (d *Decl) Key() string {
+	return declKey(d.Package, d.Recv, d.Name)
+}
+
+func declKey(pkgPath, recv, name string) string {
+	return pkgPath + "." + (&AggregatedError{Recv: recv, Func: name}).Name()
+}
+
+// Call is a call or a reference to a package level declaration made by a
+// function or by the initializer of a variable. Calls are resolved against
+// the declarations of all audited packages once their results are merged.
+type Call struct {
+	Pos token.Position
+	// Package is the import path of the referenced declaration. It is empty
+	// for methods called on values whose type is unknown.
+	Package string
+	// Recv is the receiver type of methods called on the receiver of the
+	// calling method.
+	Recv string
+	Name string
+	// Method is set for methods called on values.
+	Method bool
+	// Returned is set when the error of the call is returned by the caller.
+	Returned bool
+	// Wrapped is set when the error of the call is returned wrapped with %w.
+	Wrapped bool
+	// Idents holds the identifiers passed as arguments, such as error codes,
+	// without their package qualifier.
+	Idents []string
+	// Literals holds the unquoted string literals passed as arguments.
+	Literals []string
+	// Construction is set for calls to registered constructors.
+	Construction *Construction
+	// Checks holds the checks of the error returned by the call.
+	Checks []*Check
+	// Guards holds the qualified values the call is guarded by: the values
+	// listed by the enclosing case clauses, and the errors checked with
+	// errors.Is or == by the enclosing if statements.
+	Guards []string
+}
+
+// handlerParams are the parameter types identifying HTTP handlers.
+var handlerParams = map[string]bool{
+	"http.ResponseWriter": true,
+	"*http.Request":       true,
+	"*gin.Context":        true,
+	"echo.Context":        true,
+	"*fiber.Ctx":          true,
+}
+
+// walker collects the declarations of a file.
+type walker struct {
+	pkgPath string
+	fset    *token.FileSet
+	imports map[string]string
+	// registry holds the declared constructors.
+	registry Registry
+}
+
+// construction returns the structured form of a call to a declared or
+// built-in constructor, and nil for other calls.
+func (w *walker) construction(call *ast.CallExpr) *Construction {
+	return w.registry.construction(w.pkgPath, w.imports, call)
+}
+
+// funcDecl returns the declaration of a function with the calls of its body.
+func (w *walker) funcDecl(decl *ast.FuncDecl) *Decl {
+	d := &Decl{
+		Package: w.pkgPath,
+		Name:    decl.Name.Name,
+		Pos:     w.fset.Position(decl.Pos()),
+		End:     w.fset.Position(decl.End()),
+	}
+	var recvName string
+	if decl.Recv != nil && len(decl.Recv.List) > 0 {
+		field := decl.Recv.List[0]
+		d.Recv = recvTypeName(field.Type)
+		if len(field.Names) > 0 {
+			recvName = field.Names[0].Name
+		}
+	}
+	for _, field := range decl.Type.Params.List {
+		if handlerParams[types.ExprString(field.Type)] {
+			d.Handler = true
+		}
+	}
+	if typ, _ := ExtractFuncType(decl.Type); typ == Error {
+		d.Error = true
+	}
+	d.RPC = d.Recv != "" && decl.Name.IsExported() && rpcMethod(decl.Type)
+	if results := decl.Type.Results; results != nil && len(results.List) > 0 {
+		d.Type = w.qualify(results.List[0].Type)
+	}
+	idx := errorResultIndex(decl.Type)
+	var values []ast.Expr
+	ast.Inspect(decl.Body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FuncLit:
+			// returns of function literals do not return from decl.
+			return false
+		case *ast.ReturnStmt:
+			switch {
+			case idx < 0:
+			case len(node.Results) == 1:
+				values = append(values, node.Results[0])
+			case idx < len(node.Results):
+				values = append(values, node.Results[idx])
+			}
+		}
+		return true
+	})
+	d.Calls = w.calls(decl.Body, values, d.Recv, recvName)
+	d.Routes = w.routes(decl.Body, nil, d.Recv, recvName)
+	return d
+}
+
+// rpcMethod reports whether a function has the signature of a gRPC service
+// method, either unary, `(context.Context, *Request) (*Response, error)`, or
+// streaming, `(*Request, Service_MethodServer) error` and
+// `(Service_MethodServer) error`.
+func rpcMethod(funcType *ast.FuncType) bool {
+	var params, results []ast.Expr
+	for _, field := range funcType.Params.List {
+		for i := 0; i < len(field.Names) || i == 0 && len(field.Names) == 0; i++ {
+			params = append(params, field.Type)
+		}
+	}
+	if funcType.Results != nil {
+		for _, field := range funcType.Results.List {
+			for i := 0; i < len(field.Names) || i == 0 && len(field.Names) == 0; i++ {
+				results = append(results, field.Type)
+			}
+		}
+	}
+	if len(results) == 0 || types.ExprString(results[len(results)-1]) != "error" {
+		return false
+	}
+	switch {
+	case len(params) == 2 && len(results) == 2:
+		_, req := params[1].(*ast.StarExpr)
+		_, resp := results[0].(*ast.StarExpr)
+		return types.ExprString(params[0]) == "context.Context" && req && resp
+	case len(params) >= 1 && len(params) <= 2 && len(results) == 1:
+		stream := types.ExprString(params[len(params)-1])
+		return strings.Contains(stream, "_") && strings.HasSuffix(stream, "Server")
+	}
+	return false
+}
+
+// varDecl returns the declaration of a package level variable of type typ,
+// which may be nil, initialized with value.
+func (w *walker) varDecl(ident *ast.Ident, typ, value ast.Expr) *Decl {
+	d := &Decl{
+		Package: w.pkgPath,
+		Name:    ident.Name,
+		Pos:     w.fset.Position(ident.Pos()),
+		End:     w.fset.Position(value.End()),
+		Var:     true,
+		Calls:   w.calls(value, []ast.Expr{value}, "", ""),
+	}
+	if typ != nil {
+		d.Type = w.qualify(typ)
+	}
+	return d
+}
+
+// errorResultIndex returns the index of the error result of a function, or
+// of its only result, and -1 otherwise.
+func errorResultIndex(funcType *ast.FuncType) int {
+	if funcType.Results == nil {
+		return -1
+	}
+	idx, single := 0, funcType.Results.NumFields() == 1
+	for _, field := range funcType.Results.List {
+		if ident, ok := field.Type.(*ast.Ident); ok && ident.Name == "error" {
+			return idx
+		}
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		idx += n
+	}
+	if single {
+		return 0
+	}
+	return -1
+}
+
+// calls returns the calls made in node. The calls producing one of the
+// returned values, directly, through a variable they are assigned to or
+// wrapped with %w or errors.Join, are marked as returned, and as wrapped in
+// the latter case.
+// Returned references to package level declarations are recorded as calls too.
+func (w *walker) calls(node ast.Node, values []ast.Expr, recv, recvName string) []*Call {
+	assigned := make(map[string][]*ast.CallExpr)
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.AssignStmt:
+			assign(assigned, node.Lhs, node.Rhs)
+		case *ast.ValueSpec:
+			lhs := make([]ast.Expr, len(node.Names))
+			for i, name := range node.Names {
+				lhs[i] = name
+			}
+			assign(assigned, lhs, node.Values)
+		}
+		return true
+	})
+
+	var calls []*Call
+	guards := w.guards(node)
+	returned := make(map[*ast.CallExpr]bool)
+	// wrapped holds the values wrapped with %w and the calls they are assigned.
+	wrapped := make(map[ast.Expr]bool)
+	for len(values) > 0 {
+		value := values[0]
+		values = values[1:]
+		switch value := ast.Unparen(value).(type) {
+		case *ast.CallExpr:
+			returned[value] = true
+			for _, arg := range w.causes(value) {
+				switch arg := ast.Unparen(arg).(type) {
+				case *ast.Ident:
+					if _, ok := assigned[arg.Name]; ok {
+						values = append(values, arg)
+						wrapped[arg] = true
+					}
+				case *ast.SelectorExpr, *ast.CallExpr:
+					values = append(values, arg)
+					wrapped[arg] = true
+				}
+			}
+		case *ast.Ident:
+			if exprs, ok := assigned[value.Name]; ok {
+				for _, expr := range exprs {
+					returned[expr] = true
+					wrapped[expr] = wrapped[expr] || wrapped[value]
+				}
+			} else if value.Name != "nil" {
+				calls = append(calls, &Call{Pos: w.fset.Position(value.Pos()), Package: w.pkgPath, Name: value.Name, Returned: true, Wrapped: wrapped[value], Guards: guardsAt(guards, value.Pos())})
+			}
+		case *ast.SelectorExpr:
+			if x, ok := value.X.(*ast.Ident); ok {
+				if importPath, ok := w.imports[x.Name]; ok {
+					calls = append(calls, &Call{Pos: w.fset.Position(value.Pos()), Package: importPath, Name: value.Sel.Name, Returned: true, Wrapped: wrapped[value], Guards: guardsAt(guards, value.Pos())})
+				}
+			}
+		}
+	}
+
+	byExpr := make(map[*ast.CallExpr]*Call)
+	ast.Inspect(node, func(node ast.Node) bool {
+		expr, ok := node.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		call := w.call(expr.Fun, recv, recvName)
+		if call == nil {
+			return true
+		}
+		byExpr[expr] = call
+		call.Pos = w.fset.Position(expr.Pos())
+		call.Returned = returned[expr]
+		call.Wrapped = wrapped[expr]
+		call.Guards = guardsAt(guards, expr.Pos())
+		for _, arg := range expr.Args {
+			switch arg := arg.(type) {
+			case *ast.Ident:
+				call.Idents = append(call.Idents, arg.Name)
+			case *ast.SelectorExpr:
+				if x, ok := arg.X.(*ast.Ident); ok && w.imports[x.Name] != "" {
+					call.Idents = append(call.Idents, arg.Sel.Name)
+				}
+			case *ast.BasicLit:
+				if s, ok := stringLit(arg); ok {
+					call.Literals = append(call.Literals, s)
+				}
+			}
+		}
+		call.Construction = w.construction(expr)
+		calls = append(calls, call)
+		return true
+	})
+	for name, checks := range w.checks(node) {
+		for _, check := range checks {
+			// the check applies to the last call assigned before it.
+			var last *ast.CallExpr
+			for _, expr := range assigned[name] {
+				if expr.Pos() < check.pos && (last == nil || expr.Pos() > last.Pos()) {
+					last = expr
+				}
+			}
+			if call := byExpr[last]; call != nil {
+				call.Checks = append(call.Checks, check.Check)
+			}
+		}
+	}
+	return calls
+}
+
+// assign records the calls assigned to identifiers, either one call per
+// identifier or a single call with multiple results.
+func assign(assigned map[string][]*ast.CallExpr, lhs, rhs []ast.Expr) {
+	for i, expr := range lhs {
+		ident, ok := expr.(*ast.Ident)
+		if !ok || ident.Name == "_" {
+			continue
+		}
+		var value ast.Expr
+		switch {
+		case len(rhs) == 1:
+			value = rhs[0]
+		case i < len(rhs):
+			value = rhs[i]
+		}
+		if call, ok := ast.Unparen(value).(*ast.CallExpr); ok {
+			assigned[ident.Name] = append(assigned[ident.Name], call)
+		}
+	}
+}
+
+// call returns the callee of fun, or nil when it is not a named function.
+func (w *walker) call(fun ast.Expr, recv, recvName string) *Call {
+	switch fun := ast.Unparen(fun).(type) {
+	case *ast.Ident:
+		return &Call{Package: w.pkgPath, Name: fun.Name}
+	case *ast.SelectorExpr:
+		x, ok := fun.X.(*ast.Ident)
+		switch {
+		case ok && w.imports[x.Name] != "":
+			return &Call{Package: w.imports[x.Name], Name: fun.Sel.Name}
+		case ok && recvName != "" && x.Name == recvName:
+			return &Call{Package: w.pkgPath, Recv: recv, Name: fun.Sel.Name, Method: true}
+		default:
+			return &Call{Name: fun.Sel.Name, Method: true}
+		}
+	case *ast.IndexExpr:
+		// instantiation of a generic function.
+		return w.call(fun.X, recv, recvName)
+	}
+	return nil
+}
+
+// target returns the qualified name of the declaration a returned expression
+// calls or refers to, and an empty string when it is not known.
+func (w *walker) target(expr ast.Expr, recv, recvName string) string {
+	if call, ok := ast.Unparen(expr).(*ast.CallExpr); ok {
+		expr = call.Fun
+	}
+	call := w.call(expr, recv, recvName)
+	if call == nil || call.Package == "" {
+		return ""
+	}
+	return declKey(call.Package, call.Recv, call.Name)
+}
+
+// qualify returns the qualified name of a type expression, such as
+// `*example.com/project/pkg/apperrors.DomainError`. Predeclared types are
+// left unqualified.
+func (w *walker) qualify(expr ast.Expr) string {
+	switch expr := ast.Unparen(expr).(type) {
+	case *ast.StarExpr:
+		return "*" + w.qualify(expr.X)
+	case *ast.Ident:
+		if types.Universe.Lookup(expr.Name) != nil {
+			return expr.Name
+		}
+		return w.pkgPath + "." + expr.Name
+	case *ast.SelectorExpr:
+		if x, ok := expr.X.(*ast.Ident); ok && w.imports[x.Name] != "" {
+			return w.imports[x.Name] + "." + expr.Sel.Name
+		}
+	}
+	return types.ExprString(expr)
+}
+
+// callGraph resolves calls against the declarations of a result.
+type callGraph struct {
+	decls map[string]*Decl
+	// methods holds the declarations of methods by name.
+	methods map[string][]*Decl
+	// recvs holds the methods by their qualified name with the other
+	// receiver kind, such as `example.com/project.usecase.Get` for
+	// `example.com/project.(*usecase).Get`.
+	recvs map[string]*Decl
+}
+
+func newCallGraph(decls map[string]*Decl) *callGraph {
+	g := &callGraph{decls: decls, methods: make(map[string][]*Decl), recvs: make(map[string]*Decl)}
+	for _, d := range decls {
+		if d.Recv != "" {
+			g.methods[d.Name] = append(g.methods[d.Name], d)
+			recv := "*" + d.Recv
+			if strings.HasPrefix(d.Recv, "*") {
+				recv = d.Recv[1:]
+			}
+			g.recvs[declKey(d.Package, recv, d.Name)] = d
+		}
+	}
+	return g
+}
+
+// resolve returns the declaration a call refers to. Methods called on values
+// of unknown type are resolved when a single audited type declares them.
+func (g *callGraph) resolve(call *Call) *Decl {
+	switch {
+	case !call.Method:
+		return g.decls[declKey(call.Package, "", call.Name)]
+	case call.Recv != "":
+		recv := strings.TrimPrefix(call.Recv, "*")
+		if d, ok := g.decls[declKey(call.Package, "*"+recv, call.Name)]; ok {
+			return d
+		}
+		return g.decls[declKey(call.Package, recv, call.Name)]
+	}
+	if methods := g.methods[call.Name]; len(methods) == 1 {
+		return methods[0]
+	}
+	return nil
+}
+
+// entryDecl returns the declaration an error entry calls or refers to,
+// resolved like the call it is returned by.
+func (g *callGraph) entryDecl(e *ErrorEntry) *Decl {
+	if e.Target == "" {
+		if e.Kind == VarError {
+			return nil
+		}
+		// methods called on values of unknown type, rendered by name.
+		return g.resolve(&Call{Name: definitionName(e.Text), Method: true})
+	}
+	if d, ok := g.decls[e.Target]; ok {
+		return d
+	}
+	return g.recvs[e.Target]
+}
+
+// Callee returns the audited declaration called at a position, given the name
+// of the called function or method since calls such as `f().g()` start at the
+// same position. It returns nil when no audited declaration is called there.
+func (r *Result) Callee(posn token.Position, name string) *Decl {
+	filename, err := filepath.Abs(posn.Filename)
+	if err != nil {
+		return nil
+	}
+	graph := newCallGraph(r.Decls)
+	for _, d := range r.Decls {
+		if d.Pos.Line > posn.Line || posn.Line > d.End.Line {
+			continue
+		}
+		for _, call := range d.Calls {
+			if call.Name != name || call.Pos.Line != posn.Line || call.Pos.Column != posn.Column {
+				continue
+			}
+			if abs, err := filepath.Abs(call.Pos.Filename); err == nil && abs == filename {
+				return graph.resolve(call)
+			}
+		}
+	}
+	return nil
+}
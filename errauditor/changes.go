@@ -0,0 +1,176 @@
+package errauditor
+
+import (
+	"bufio"
+	"fmt"
+	"go/token"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LineRange is an inclusive range of line numbers.
+type LineRange struct {
+	Start, End int
+}
+
+// Changes holds the lines touched by a patch, keyed by absolute file path.
+type Changes map[string][]LineRange
+
+// ParsePatch reads the lines added or modified by a unified diff. File names in
+// the patch are resolved relative to dir. Hunks that only delete lines mark the
+// line preceding the deletion so that the enclosing function is still reported.
+func ParsePatch(r io.Reader, dir string) (Changes, error) {
+	changes := make(Changes)
+	var file string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			name := strings.TrimPrefix(line, "+++ ")
+			if i := strings.IndexByte(name, '\t'); i >= 0 {
+				name = name[:i]
+			}
+			if name == "/dev/null" {
+				file = ""
+				continue
+			}
+			name = strings.TrimPrefix(name, "b/")
+			file = filepath.Clean(filepath.Join(dir, filepath.FromSlash(name)))
+		case strings.HasPrefix(line, "@@ ") && file != "":
+			lr, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, err
+			}
+			changes[file] = append(changes[file], lr)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// parseHunkHeader returns the new file lines of a hunk header such as
+// `@@ -10,2 +12,3 @@ func name()`.
+func parseHunkHeader(line string) (LineRange, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+		return LineRange{}, fmt.Errorf("invalid hunk header %q", line)
+	}
+	start, count := strings.TrimPrefix(fields[2], "+"), "1"
+	if i := strings.IndexByte(start, ','); i >= 0 {
+		start, count = start[:i], start[i+1:]
+	}
+	s, err := strconv.Atoi(start)
+	if err != nil {
+		return LineRange{}, fmt.Errorf("invalid hunk header %q: %v", line, err)
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil {
+		return LineRange{}, fmt.Errorf("invalid hunk header %q: %v", line, err)
+	}
+	if n == 0 {
+		return LineRange{Start: s, End: s}, nil
+	}
+	return LineRange{Start: s, End: s + n - 1}, nil
+}
+
+// touches reports whether any changed line of filename lies within [start, end].
+func (c Changes) touches(filename string, start, end int) bool {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return false
+	}
+	for _, lr := range c[abs] {
+		if lr.Start <= end && start <= lr.End {
+			return true
+		}
+	}
+	return false
+}
+
+// enclosingDecl returns the function or method spanning a position, or nil.
+func (r *Result) enclosingDecl(posn token.Position) *Decl {
+	for _, d := range r.Decls {
+		if d.End.IsValid() && d.Pos.Filename == posn.Filename && d.Pos.Line <= posn.Line && posn.Line <= d.End.Line {
+			return d
+		}
+	}
+	return nil
+}
+
+// Filter keeps only the functions whose body was touched by the changes, and
+// the findings within them or on a touched line outside functions. It scopes
+// the other sections of the result the same way: the entry points and error
+// types whose declarations were touched, and the call sites of the error
+// handling on changed lines. The HTTP handlers and RPC methods that were not
+// touched are no longer marked as such, so that the HTTP status and gRPC
+// reports only list the changed ones.
+func (c Changes) Filter(r *Result) {
+	touched := func(d *Decl) bool {
+		return d != nil && c.touches(d.Pos.Filename, d.Pos.Line, d.End.Line)
+	}
+
+	agErrors := r.AggregatedErrors[:0]
+	r.WrappedErrorCount, r.ConstErrorCount = 0, 0
+	for _, agError := range r.AggregatedErrors {
+		if c.touches(agError.Pos.Filename, agError.Pos.Line, agError.End.Line) {
+			agErrors = append(agErrors, agError)
+			r.WrappedErrorCount += agError.WrappedErrorCount
+			r.ConstErrorCount += agError.ConstErrorCount
+		}
+	}
+	r.AggregatedErrors = agErrors
+
+	findings := r.Findings[:0]
+	for _, finding := range r.Findings {
+		// findings positioned at a declaration, such as a handler receiving a
+		// code from a changed call, depend on the lines of its body.
+		if d := r.enclosingDecl(finding.Pos); d != nil && touched(d) ||
+			d == nil && c.touches(finding.Pos.Filename, finding.Pos.Line, finding.Pos.Line) {
+			findings = append(findings, finding)
+		}
+	}
+	r.Findings = findings
+
+	entryPoints := r.EntryPoints[:0]
+	for _, entry := range r.EntryPoints {
+		if touched(entry.Decl) {
+			entryPoints = append(entryPoints, entry)
+		}
+	}
+	r.EntryPoints = entryPoints
+
+	handlings := r.Handlings[:0]
+	for _, h := range r.Handlings {
+		if c.touches(h.Pos.Filename, h.Pos.Line, h.Pos.Line) {
+			handlings = append(handlings, h)
+		}
+	}
+	r.Handlings = handlings
+
+	errorTypes := r.ErrorTypes[:0]
+	for _, t := range r.ErrorTypes {
+		pkg := t.Type[:strings.LastIndex(t.Type, ".")]
+		for _, m := range t.Methods {
+			if touched(r.Decls[declKey(pkg, m.recv, m.Name)]) {
+				errorTypes = append(errorTypes, t)
+				break
+			}
+		}
+	}
+	r.ErrorTypes = errorTypes
+
+	// the declarations are copied since they may be shared with other results.
+	for key, d := range r.Decls {
+		if (d.Handler || d.RPC) && !touched(d) {
+			copied := *d
+			copied.Handler, copied.RPC = false, false
+			r.Decls[key] = &copied
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package errauditor
+
+import (
+	"go/token"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const examplePatch = `diff --git a/project/usecase.go b/project/usecase.go
+index 1111111..2222222 100644
+--- a/project/usecase.go
++++ b/project/usecase.go
+@@ -14,0 +15,2 @@ func GetAddressByUser() error {
++	if address == "x" {
++	}
+@@ -40 +41,0 @@ func GetDrilldowns() (error, int) {
+-	// removed
+diff --git a/project/old.go b/project/old.go
+deleted file mode 100644
+--- a/project/old.go
++++ /dev/null
+@@ -1,3 +0,0 @@
+-package project
+`
+
+func TestParsePatch(t *testing.T) {
+	dir := filepath.FromSlash("/src")
+	changes, err := ParsePatch(strings.NewReader(examplePatch), dir)
+	require.NoError(t, err)
+	require.Equal(t, Changes{
+		filepath.Join(dir, "project", "usecase.go"): {{Start: 15, End: 16}, {Start: 41, End: 41}},
+	}, changes)
+}
+
+func TestChangesFilter(t *testing.T) {
+	filename, err := filepath.Abs("usecase.go")
+	require.NoError(t, err)
+	changes := Changes{filename: {{Start: 15, End: 16}}}
+
+	posn := func(line int) token.Position {
+		return token.Position{Filename: "usecase.go", Line: line}
+	}
+	result := &Result{
+		AggregatedErrors: []*AggregatedError{
+			{Func: "GetAddressByUser", Pos: posn(12), End: posn(20), WrappedErrorCount: 1},
+			{Func: "GetDrilldown", Pos: posn(22), End: posn(25), WrappedErrorCount: 1},
+		},
+		Findings: []*Finding{
+			{Func: "GetAddressByUser", Pos: posn(13)},
+			{Func: "GetAddressByUser", Pos: posn(16)},
+		},
+		WrappedErrorCount: 2,
+	}
+	changes.Filter(result)
+
+	require.Len(t, result.AggregatedErrors, 1)
+	require.Equal(t, "GetAddressByUser", result.AggregatedErrors[0].Func)
+	require.EqualValues(t, 1, result.WrappedErrorCount)
+	require.Len(t, result.Findings, 1)
+	require.Equal(t, 16, result.Findings[0].Pos.Line)
+}
+
+const sectionsSrc = `package project
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"example.com/project/pkg/apperrors"
+)
+
+type server struct{}
+
+func (s *server) GetUser(ctx context.Context, req *Request) (*User, error) {
+	return nil, apperrors.ErrUserNotFound
+}
+
+func (s *server) DeleteUser(ctx context.Context, req *Request) (*User, error) {
+	return nil, apperrors.ErrUnauthorized
+}
+
+func Handle(w http.ResponseWriter, r *http.Request) {
+	err := find()
+	if errors.Is(err, apperrors.ErrRecordNotFound) {
+		return
+	}
+}
+
+func Other(w http.ResponseWriter, r *http.Request) {
+	err := find()
+	if errors.Is(err, apperrors.ErrRecordNotFound) {
+		return
+	}
+}
+
+func find() error {
+	return apperrors.ErrRecordNotFound
+}
+
+type NotFound struct{}
+
+func (NotFound) Error() string { return "not found" }
+
+type Conflict struct{}
+
+func (Conflict) Error() string { return "conflict" }
+`
+
+func TestChangesFilterSections(t *testing.T) {
+	filename, err := filepath.Abs("usecase.go")
+	require.NoError(t, err)
+	changes := Changes{filename: {{Start: 14, End: 14}, {Start: 22, End: 22}, {Start: 41, End: 41}}}
+
+	result := runSource(t, "example.com/project", sectionsSrc)
+	other := result.Decls["example.com/project.Other"]
+	for _, name := range []string{"Handle", "Other"} {
+		result.EntryPoints = append(result.EntryPoints, &EntryPoint{Decl: result.Decls["example.com/project."+name]})
+	}
+	result.Handlings = result.ErrorHandling()
+	require.Len(t, result.Handlings, 2)
+	result.ErrorTypes = result.ErrorTypeHierarchy()
+	require.Len(t, result.ErrorTypes, 2)
+	changes.Filter(result)
+
+	require.Len(t, result.EntryPoints, 1)
+	require.Equal(t, "Handle", result.EntryPoints[0].Decl.Name)
+	require.Len(t, result.Handlings, 1)
+	require.Equal(t, "example.com/project.Handle", result.Handlings[0].Caller)
+	require.Len(t, result.ErrorTypes, 1)
+	require.Equal(t, "example.com/project.NotFound", result.ErrorTypes[0].Type)
+
+	require.True(t, result.Decls["example.com/project.Handle"].Handler)
+	require.False(t, result.Decls["example.com/project.Other"].Handler)
+	// the declarations shared with the unfiltered result are left unchanged.
+	require.True(t, other.Handler)
+	rpcs, err := result.GRPCCodes()
+	require.NoError(t, err)
+	require.Len(t, rpcs, 1)
+	require.Equal(t, "project.(*server).GetUser", rpcs[0].Method)
+}
+
+func TestChangesFilterHandlerBody(t *testing.T) {
+	filename, err := filepath.Abs("handler.go")
+	require.NoError(t, err)
+	// only the body of GetHandler, declared on line 33, is changed.
+	changes := Changes{filename: {{Start: 34, End: 34}}}
+
+	result := auditStatuses(t)
+	require.Len(t, result.Findings, 1)
+	changes.Filter(result)
+	require.Len(t, result.Findings, 1)
+	require.Equal(t, HTTPStatusRule, result.Findings[0].Rule)
+	require.Equal(t, 33, result.Findings[0].Pos.Line)
+
+	result = auditStatuses(t)
+	Changes{filename: {{Start: 38, End: 40}}}.Filter(result)
+	require.Empty(t, result.Findings)
+}
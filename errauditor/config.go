@@ -0,0 +1,37 @@
+package errauditor
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Config holds the settings shared by the command line, through its -config
+// file, and the golangci-lint plugin.
+type Config struct {
+	// Exclude lists regular expressions matching directories to skip.
+	Exclude []string `json:"exclude,omitempty"`
+	// Baseline is the path of a baseline file whose findings are suppressed.
+	Baseline string `json:"baseline,omitempty"`
+	// IncludeGenerated audits generated files for the command line.
+	// golangci-lint decides on generated files itself.
+	IncludeGenerated bool `json:"include-generated,omitempty"`
+	// Tests audits _test.go files for the command line. golangci-lint
+	// decides on test files itself.
+	Tests bool `json:"tests,omitempty"`
+	// ReportUnusedIgnores reports ignore directives that suppress no finding.
+	ReportUnusedIgnores bool `json:"report-unused-ignores,omitempty"`
+	// Constructors declares the functions constructing or wrapping errors.
+	Constructors []Constructor `json:"constructors,omitempty"`
+}
+
+// ReadConfig decodes a JSON configuration. Unknown settings are rejected so
+// that typos do not go unnoticed.
+func ReadConfig(r io.Reader) (*Config, error) {
+	var cfg Config
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
@@ -0,0 +1,157 @@
+package errauditor
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Constructor declares a function that constructs or wraps errors, such as
+// `apperrors.ErrInternalServerError` or `github.com/pkg/errors.Wrapf`.
+type Constructor struct {
+	// Func is the function qualified by its import path or package name.
+	Func string `json:"func"`
+	// Message, Code and Cause are the indexes of the arguments holding the
+	// message, the error code and the wrapped error, or -1 when the
+	// constructor has no such argument.
+	Message int `json:"message"`
+	Code    int `json:"code"`
+	Cause   int `json:"cause"`
+}
+
+// UnmarshalJSON decodes a constructor, arguments left out default to -1.
+func (c *Constructor) UnmarshalJSON(data []byte) error {
+	type constructor Constructor
+	decoded := constructor{Message: -1, Code: -1, Cause: -1}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*c = Constructor(decoded)
+	return nil
+}
+
+// Construction is an error returned through a registered constructor, with
+// the source text of its arguments.
+type Construction struct {
+	Constructor string
+	Code        string
+	Message     string
+	Cause       string
+}
+
+// String renders the construction like a call with named arguments, e.g.
+// `Wrapf(message: "find user", cause: err)`.
+func (c *Construction) String() string {
+	var args []string
+	if c.Code != "" {
+		args = append(args, "code: "+c.Code)
+	}
+	if c.Message != "" {
+		args = append(args, "message: "+c.Message)
+	}
+	if c.Cause != "" {
+		args = append(args, "cause: "+c.Cause)
+	}
+	name := c.Constructor[strings.LastIndex(c.Constructor, ".")+1:]
+	return name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// Registry holds the declared constructors by qualified name.
+type Registry map[string]*Constructor
+
+// NewRegistry validates the constructors and indexes them by name.
+func NewRegistry(constructors []Constructor) (Registry, error) {
+	registry := make(Registry, len(constructors))
+	for i := range constructors {
+		c := &constructors[i]
+		if i := strings.LastIndex(c.Func, "."); i <= 0 || i == len(c.Func)-1 {
+			return nil, fmt.Errorf("constructor %q is not qualified by its package", c.Func)
+		}
+		if c.Message < -1 || c.Code < -1 || c.Cause < -1 {
+			return nil, fmt.Errorf("constructor %q has a negative argument index", c.Func)
+		}
+		registry[c.Func] = c
+	}
+	return registry, nil
+}
+
+// lookup returns the constructor called by fun, resolving package names
+// through the imports of the file, or the built-in constructor it calls. Calls
+// without a selector refer to pkgPath.
+func (reg Registry) lookup(pkgPath string, imports map[string]string, fun ast.Expr) *Constructor {
+	var importPath, name string
+	switch fun := fun.(type) {
+	case *ast.Ident:
+		importPath, name = pkgPath, fun.Name
+	case *ast.SelectorExpr:
+		x, ok := fun.X.(*ast.Ident)
+		if !ok {
+			return nil
+		}
+		if importPath, ok = imports[x.Name]; !ok {
+			return nil
+		}
+		name = fun.Sel.Name
+	default:
+		return nil
+	}
+	if c, ok := reg[importPath+"."+name]; ok {
+		return c
+	}
+	if c, ok := reg[packageName(importPath)+"."+name]; ok {
+		return c
+	}
+	return builtins[importPath+"."+name]
+}
+
+// construction extracts the arguments of a call to a registered constructor.
+func (reg Registry) construction(pkgPath string, imports map[string]string, call *ast.CallExpr) *Construction {
+	c := reg.lookup(pkgPath, imports, call.Fun)
+	if c == nil {
+		return nil
+	}
+	arg := func(i int) string {
+		if i < 0 || i >= len(call.Args) {
+			return ""
+		}
+		return types.ExprString(call.Args[i])
+	}
+	return &Construction{
+		Constructor: c.Func,
+		Code:        arg(c.Code),
+		Message:     arg(c.Message),
+		Cause:       arg(c.Cause),
+	}
+}
+
+// fileImports maps the names under which file refers to its imports to their
+// import paths.
+func fileImports(file *ast.File) map[string]string {
+	imports := make(map[string]string, len(file.Imports))
+	for _, spec := range file.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := packageName(importPath)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		imports[name] = importPath
+	}
+	return imports
+}
+
+// packageName guesses the package name of an import path from its last
+// element, skipping major version suffixes such as `/v2`.
+func packageName(importPath string) string {
+	name := path.Base(importPath)
+	if len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" && path.Dir(importPath) != "." {
+		name = path.Base(path.Dir(importPath))
+	}
+	return name
+}
@@ -0,0 +1,89 @@
+package errauditor
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const constructorSrc = `package project
+
+import (
+	"fmt"
+
+	pkgerrors "github.com/pkg/errors"
+	"example.com/project/pkg/apperrors"
+)
+
+func GetUser() error {
+	err := find()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "find user %d", 1)
+	}
+	if ok {
+		return newError("NotFound", "user not found")
+	}
+	return apperrors.ErrInternalServerError("done")
+}
+
+func newError(code, message string) error {
+	return fmt.Errorf("%s: %s", code, message)
+}
+`
+
+func TestReadConfigConstructors(t *testing.T) {
+	cfg, err := ReadConfig(strings.NewReader(`{"constructors": [
+		{"func": "github.com/pkg/errors.Wrapf", "cause": 0, "message": 1},
+		{"func": "apperrors.ErrInternalServerError", "message": 0}
+	]}`))
+	require.NoError(t, err)
+	require.Equal(t, []Constructor{
+		{Func: "github.com/pkg/errors.Wrapf", Message: 1, Code: -1, Cause: 0},
+		{Func: "apperrors.ErrInternalServerError", Message: 0, Code: -1, Cause: -1},
+	}, cfg.Constructors)
+
+	_, err = NewRegistry([]Constructor{{Func: "Wrapf", Message: -1, Code: -1, Cause: -1}})
+	require.Error(t, err)
+}
+
+func TestAuditorConstructors(t *testing.T) {
+	auditor := NewAuditor()
+	require.NoError(t, auditor.Register(
+		Constructor{Func: "github.com/pkg/errors.Wrapf", Message: 1, Code: -1, Cause: 0},
+		Constructor{Func: "apperrors.ErrInternalServerError", Message: 0, Code: -1, Cause: -1},
+		Constructor{Func: "example.com/project.newError", Message: 1, Code: 0, Cause: -1},
+	))
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "usecase.go", constructorSrc, parser.ParseComments)
+	require.NoError(t, err)
+	require.NoError(t, auditor.Run("example.com/project", f, fset))
+
+	result := auditor.Result()
+	require.Len(t, result.AggregatedErrors, 2)
+	agError := result.AggregatedErrors[0]
+	require.Equal(t, []*Construction{
+		{Constructor: "github.com/pkg/errors.Wrapf", Message: `"find user %d"`, Cause: "err"},
+		{Constructor: "example.com/project.newError", Code: `"NotFound"`, Message: `"user not found"`},
+		{Constructor: "apperrors.ErrInternalServerError", Message: `"done"`},
+	}, agError.Constructions)
+	require.Equal(t, []string{
+		`Wrapf(message: "find user %d", cause: err)`,
+		`newError(code: "NotFound", message: "user not found")`,
+		`ErrInternalServerError(message: "done")`,
+	}, agError.ErrorStrings())
+	require.EqualValues(t, 1, agError.WrappedErrorCount)
+
+	// without the registry only built-in constructors are structured.
+	result = runSource(t, "example.com/project", constructorSrc)
+	require.Equal(t, []string{`Wrapf(message: "find user %d", cause: err)`, `ErrInternalServerError("done",)`}, result.AggregatedErrors[0].ErrorStrings())
+	require.Len(t, result.AggregatedErrors[0].Constructions, 1)
+}
+
+func TestPackageName(t *testing.T) {
+	require.Equal(t, "errors", packageName("github.com/pkg/errors"))
+	require.Equal(t, "redis", packageName("github.com/go-redis/redis/v8"))
+	require.Equal(t, "v1", packageName("v1"))
+}
@@ -0,0 +1,169 @@
+package errauditor
+
+import (
+	"io"
+	"sort"
+
+	"github.com/fatih/color"
+)
+
+// FuncDiff lists the errors added to and removed from the returnable set of an
+// exported function between two results.
+type FuncDiff struct {
+	Package string
+	Func    string
+	Added   []string
+	Removed []string
+}
+
+// DiffResults compares the error sets of the exported functions of two results,
+// including the errors they return through their calls. Functions missing
+// from one of the results are treated as having an empty error set there.
+func DiffResults(oldResult, newResult *Result) []*FuncDiff {
+	type key struct{ pkg, fn string }
+	errorSets := func(r *Result) map[key]map[string]bool {
+		sets := make(map[key]map[string]bool)
+		for _, agError := range r.AggregatedErrors {
+			if !agError.IsExported() {
+				continue
+			}
+			k := key{agError.Package, agError.Name()}
+			if sets[k] == nil {
+				sets[k] = make(map[string]bool)
+			}
+			for _, v := range agError.tracedStrings() {
+				sets[k][v] = true
+			}
+		}
+		return sets
+	}
+	oldSets, newSets := errorSets(oldResult), errorSets(newResult)
+
+	keys := make(map[key]bool)
+	for k := range oldSets {
+		keys[k] = true
+	}
+	for k := range newSets {
+		keys[k] = true
+	}
+
+	var diffs []*FuncDiff
+	for k := range keys {
+		diff := &FuncDiff{Package: k.pkg, Func: k.fn}
+		for v := range newSets[k] {
+			if !oldSets[k][v] {
+				diff.Added = append(diff.Added, v)
+			}
+		}
+		for v := range oldSets[k] {
+			if !newSets[k][v] {
+				diff.Removed = append(diff.Removed, v)
+			}
+		}
+		if len(diff.Added) == 0 && len(diff.Removed) == 0 {
+			continue
+		}
+		sort.Strings(diff.Added)
+		sort.Strings(diff.Removed)
+		diffs = append(diffs, diff)
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].Package != diffs[j].Package {
+			return diffs[i].Package < diffs[j].Package
+		}
+		return diffs[i].Func < diffs[j].Func
+	})
+	return diffs
+}
+
+// WriteDiff writes the differences as colored terminal output.
+func WriteDiff(w io.Writer, diffs []*FuncDiff) error {
+	white := color.New(color.FgWhite)
+	green := color.New(color.FgGreen)
+	red := color.New(color.FgRed)
+
+	for _, diff := range diffs {
+		if _, err := white.Fprintf(w, "%s.%s\n", diff.Package, diff.Func); err != nil {
+			return err
+		}
+		for _, v := range diff.Added {
+			if _, err := green.Fprintf(w, "+++%s \n", v); err != nil {
+				return err
+			}
+		}
+		for _, v := range diff.Removed {
+			if _, err := red.Fprintf(w, "---%s \n", v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ChangedFuncs returns the functions of newResult whose error set differs from
+// oldResult, including functions that are new and functions returning the
+// changed errors of their callees. Functions that no longer
+// return any error are returned from oldResult with an empty error set.
+func ChangedFuncs(oldResult, newResult *Result) []*AggregatedError {
+	type key struct{ pkg, fn string }
+	byKey := func(r *Result) map[key]*AggregatedError {
+		m := make(map[key]*AggregatedError, len(r.AggregatedErrors))
+		for _, agError := range r.AggregatedErrors {
+			m[key{agError.Package, agError.Name()}] = agError
+		}
+		return m
+	}
+	oldFuncs, newFuncs := byKey(oldResult), byKey(newResult)
+
+	var changed []*AggregatedError
+	for _, agError := range newResult.AggregatedErrors {
+		prev, ok := oldFuncs[key{agError.Package, agError.Name()}]
+		if !ok || !sameErrors(prev.tracedStrings(), agError.tracedStrings()) {
+			changed = append(changed, agError)
+		}
+	}
+	for _, agError := range oldResult.AggregatedErrors {
+		if _, ok := newFuncs[key{agError.Package, agError.Name()}]; !ok {
+			gone := *agError
+			gone.Errors, gone.Traces = nil, nil
+			changed = append(changed, &gone)
+		}
+	}
+	return changed
+}
+
+// tracedStrings returns the sorted rendering of the errors a function can
+// return and of the errors they wrap, except variables. The errors returned
+// through its calls are qualified by the function they originate from, such
+// as `ErrRecordNotFound() from project.(*repo).Find`, so that positions do not
+// take part in the comparison.
+func (a *AggregatedError) tracedStrings() []string {
+	var errors []string
+	for _, e := range a.traced() {
+		suffix := ""
+		if origin := e.Trace[len(e.Trace)-1].Func; origin != a.Key() {
+			suffix = " from " + shortName(origin)
+		}
+		if e.Entry == nil {
+			errors = append(errors, e.Error+suffix)
+			continue
+		}
+		for _, v := range (&AggregatedError{Errors: []*ErrorEntry{e.Entry}}).ErrorStrings() {
+			errors = append(errors, v+suffix)
+		}
+	}
+	sort.Strings(errors)
+	return errors
+}
+
+func sameErrors(x, y []string) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,117 @@
+package errauditor
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffResults(t *testing.T) {
+	oldResult := &Result{AggregatedErrors: []*AggregatedError{
+		{Package: "example.com/project", Func: "GetAddressByUser", Errors: entries("ErrRecordNotFound()")},
+		{Package: "example.com/project", Recv: "*usecase", Func: "Get", Errors: entries("ErrRecordNotFound()")},
+		{Package: "example.com/project", Func: "Removed", Errors: entries("ErrDefault()")},
+	}}
+	newResult := &Result{AggregatedErrors: []*AggregatedError{
+		{Package: "example.com/project", Func: "GetAddressByUser", Errors: entries("ErrRecordNotFound()", "ErrUnauthorized()")},
+		{Package: "example.com/project", Recv: "*usecase", Func: "Get", Errors: entries("ErrUnauthorized()")},
+		{Package: "example.com/project", Recv: "*Usecase", Func: "Get", Errors: entries("ErrDefault()")},
+	}}
+
+	diffs := DiffResults(oldResult, newResult)
+	require.Equal(t, []*FuncDiff{
+		{Package: "example.com/project", Func: "(*Usecase).Get", Added: []string{"ErrDefault()"}},
+		{Package: "example.com/project", Func: "GetAddressByUser", Added: []string{"ErrUnauthorized()"}},
+		{Package: "example.com/project", Func: "Removed", Removed: []string{"ErrDefault()"}},
+	}, diffs)
+}
+
+func TestChangedFuncs(t *testing.T) {
+	oldResult := &Result{AggregatedErrors: []*AggregatedError{
+		{Package: "example.com/project", Func: "Same", Errors: entries("ErrRecordNotFound()")},
+		{Package: "example.com/project", Func: "Changed", Errors: entries("ErrRecordNotFound()")},
+		{Package: "example.com/project", Func: "Gone", Errors: entries("ErrDefault()")},
+	}}
+	newResult := &Result{AggregatedErrors: []*AggregatedError{
+		{Package: "example.com/project", Func: "Same", Errors: entries("ErrRecordNotFound()")},
+		{Package: "example.com/project", Func: "Changed", Errors: entries("ErrUnauthorized()")},
+		{Package: "example.com/project", Func: "New", Errors: entries("ErrDefault()")},
+	}}
+
+	changed := ChangedFuncs(oldResult, newResult)
+	require.Len(t, changed, 3)
+	require.Equal(t, "Changed", changed[0].Func)
+	require.Equal(t, "New", changed[1].Func)
+	require.Equal(t, "Gone", changed[2].Func)
+	require.Empty(t, changed[2].Errors)
+	require.NotEmpty(t, oldResult.AggregatedErrors[2].Errors)
+}
+
+const diffHandlerSrc = `package project
+
+import "example.com/project/pkg/apperrors"
+
+func GetUser(id string) error {
+	if id == "" {
+		return apperrors.ErrUnauthorized
+	}
+	return find(id)
+}
+`
+
+func TestDiffCallees(t *testing.T) {
+	audit := func(repoSrc string) *Result {
+		auditor := NewAuditor()
+		fset := token.NewFileSet()
+		for _, file := range []struct{ name, src string }{
+			{"handler.go", diffHandlerSrc},
+			{"repo.go", repoSrc},
+		} {
+			f, err := parser.ParseFile(fset, file.name, file.src, 0)
+			require.NoError(t, err)
+			require.NoError(t, auditor.Run("example.com/project", f, fset))
+		}
+		return auditor.Result()
+	}
+	oldResult := audit(`package project
+
+import "example.com/project/pkg/apperrors"
+
+func find(id string) error {
+	return apperrors.ErrRecordNotFound
+}
+`)
+	// only the callee changes, and its error moves down a line.
+	newResult := audit(`package project
+
+import "example.com/project/pkg/apperrors"
+
+func find(id string) error {
+	if id == "admin" {
+		return apperrors.ErrSessionExpired
+	}
+
+	return apperrors.ErrRecordNotFound
+}
+`)
+
+	require.Equal(t, []*FuncDiff{
+		{Package: "example.com/project", Func: "GetUser", Added: []string{"ErrSessionExpired() from project.find"}},
+	}, DiffResults(oldResult, newResult))
+
+	var changed []string
+	for _, agError := range ChangedFuncs(oldResult, newResult) {
+		changed = append(changed, agError.Name())
+	}
+	require.Equal(t, []string{"GetUser", "find"}, changed)
+}
+
+func entries(texts ...string) []*ErrorEntry {
+	entries := make([]*ErrorEntry, len(texts))
+	for i, text := range texts {
+		entries[i] = &ErrorEntry{Text: text}
+	}
+	return entries
+}
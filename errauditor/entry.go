@@ -0,0 +1,106 @@
+package errauditor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EntryPoint is a function an audit is scoped to, with the errors it can
+// return aggregated through the functions it calls.
+type EntryPoint struct {
+	Decl   *Decl
+	Errors []*TracedError
+}
+
+// Scope restricts the result to the entry points named like in Lookup and to
+// the functions reachable from them, and aggregates the errors of each entry
+// point. Handlings and error types, computed on the whole result beforehand,
+// are kept for the reachable callers, methods and returners.
+func (r *Result) Scope(names ...string) error {
+	var entries []*Decl
+	for _, name := range names {
+		decls := r.Lookup(name)
+		if len(decls) == 0 {
+			return fmt.Errorf("entry point %s not found", name)
+		}
+		entries = append(entries, decls...)
+	}
+	graph := newCallGraph(r.Decls)
+	agErrors := r.aggregatedErrors()
+
+	reachable := make(map[string]*Decl)
+	var visit func(d *Decl)
+	visit = func(d *Decl) {
+		if _, ok := reachable[d.Key()]; ok {
+			return
+		}
+		reachable[d.Key()] = d
+		for _, call := range d.Calls {
+			if callee := graph.resolve(call); callee != nil {
+				visit(callee)
+			}
+		}
+	}
+	r.EntryPoints = nil
+	for _, d := range entries {
+		visit(d)
+		r.EntryPoints = append(r.EntryPoints, &EntryPoint{Decl: d, Errors: traceErrors(d, graph, agErrors)})
+	}
+
+	filtered := r.AggregatedErrors[:0]
+	r.WrappedErrorCount, r.ConstErrorCount = 0, 0
+	for _, agError := range r.AggregatedErrors {
+		if _, ok := reachable[agError.Key()]; ok {
+			filtered = append(filtered, agError)
+			r.WrappedErrorCount += agError.WrappedErrorCount
+			r.ConstErrorCount += agError.ConstErrorCount
+		}
+	}
+	r.AggregatedErrors = filtered
+
+	findings := r.Findings[:0]
+	for _, finding := range r.Findings {
+		for _, d := range reachable {
+			if d.Pos.Filename == finding.Pos.Filename && d.Pos.Line <= finding.Pos.Line && finding.Pos.Line <= d.End.Line {
+				findings = append(findings, finding)
+				break
+			}
+		}
+	}
+	r.Findings = findings
+
+	handlings := r.Handlings[:0]
+	for _, h := range r.Handlings {
+		if _, ok := reachable[h.Caller]; ok {
+			handlings = append(handlings, h)
+		}
+	}
+	r.Handlings = handlings
+
+	errorTypes := r.ErrorTypes[:0]
+	for _, t := range r.ErrorTypes {
+		if t.reachable(reachable) {
+			errorTypes = append(errorTypes, t)
+		}
+	}
+	r.ErrorTypes = errorTypes
+	r.Decls = reachable
+	return nil
+}
+
+// reachable reports whether a method or a returner of an error type is among
+// the declarations.
+func (t *CustomErrorType) reachable(decls map[string]*Decl) bool {
+	pkg := t.Type[:strings.LastIndex(t.Type, ".")]
+	for _, m := range t.Methods {
+		if _, ok := decls[declKey(pkg, m.recv, m.Name)]; ok {
+			return true
+		}
+	}
+	for _, fn := range t.Returners {
+		if _, ok := decls[fn]; ok {
+			return true
+		}
+	}
+	return false
+}
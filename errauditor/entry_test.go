@@ -0,0 +1,141 @@
+package errauditor
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const updateSrc = `package project
+
+import "fmt"
+
+func (u *usecase) Update(id string) error {
+	_, err := u.Get(id)
+	if err != nil {
+		return fmt.Errorf("update %s: %w", id, err)
+	}
+	return nil
+}
+`
+
+func auditEntryPoints(t *testing.T) *Result {
+	t.Helper()
+
+	auditor := NewAuditor()
+	fset := token.NewFileSet()
+	for _, file := range []struct{ pkgPath, name, src string }{
+		{"example.com/project/pkg/apperrors", "errors.go", apperrorsSrc},
+		{"example.com/project", "handler.go", handlerSrc},
+		{"example.com/project", "update.go", updateSrc},
+	} {
+		f, err := parser.ParseFile(fset, file.name, file.src, 0)
+		require.NoError(t, err)
+		require.NoError(t, auditor.Run(file.pkgPath, f, fset))
+	}
+	return auditor.Result()
+}
+
+func TestScope(t *testing.T) {
+	result := auditEntryPoints(t)
+	require.Error(t, result.Scope("project.Missing"))
+
+	require.NoError(t, result.Scope("project.usecase.Update"))
+	require.Len(t, result.EntryPoints, 1)
+	require.Equal(t, "example.com/project.(*usecase).Update", result.EntryPoints[0].Decl.Key())
+	var errors []string
+	for _, e := range result.EntryPoints[0].Errors {
+		errors = append(errors, e.Error+" "+traceString(e.Trace))
+	}
+	require.Equal(t, []string{
+		`Errorf("update %s: %w",) project.(*usecase).Update (update.go:8)`,
+		`ErrInternalServerError("admin",) project.(*usecase).Update (update.go:6) -> project.(*usecase).Get (handler.go:28)`,
+		"ErrRecordNotFound() project.(*usecase).Update (update.go:6) -> project.(*usecase).Get (handler.go:23) -> project.(*repo).Find (handler.go:13)",
+	}, errors)
+
+	var funcs []string
+	for _, agError := range result.AggregatedErrors {
+		funcs = append(funcs, agError.Name())
+	}
+	require.Equal(t, []string{"(*repo).Find", "(*usecase).Get", "(*usecase).Update"}, funcs)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteText(&buf, result))
+	require.Contains(t, buf.String(), "project.(*usecase).Update (update.go:6) -> project.(*usecase).Get (handler.go:23) -> project.(*repo).Find (handler.go:13)")
+}
+
+func TestScopeHandler(t *testing.T) {
+	result := auditEntryPoints(t)
+	require.NoError(t, result.Scope("example.com/project.(*usecase).GetHandler"))
+
+	var errors []string
+	for _, e := range result.EntryPoints[0].Errors {
+		errors = append(errors, e.Error+" "+traceString(e.Trace))
+	}
+	require.Equal(t, []string{
+		`ErrInternalServerError("admin",) project.(*usecase).GetHandler (handler.go:34) -> project.(*usecase).Get (handler.go:28)`,
+		"ErrRecordNotFound() project.(*usecase).GetHandler (handler.go:34) -> project.(*usecase).Get (handler.go:23) -> project.(*repo).Find (handler.go:13)",
+	}, errors)
+}
+
+func TestScopeHandlings(t *testing.T) {
+	result := runSource(t, "example.com/project", `package project
+
+import "errors"
+
+var ErrNotFound = errors.New("not found")
+
+func find() error {
+	return errors.New("unable to find")
+}
+
+func Get() error {
+	err := find()
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return nil
+}
+
+func List() error {
+	return find()
+}
+
+func Delete() error {
+	err := find()
+	if errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return nil
+}
+`)
+	result.Handlings = result.ErrorHandling()
+	require.Len(t, result.Handlings, 2)
+	require.NoError(t, result.Scope("project.Get"))
+
+	// find is reachable from Get but the call of Delete is not.
+	require.Len(t, result.Handlings, 1)
+	require.Equal(t, "example.com/project.Get", result.Handlings[0].Caller)
+}
+
+func TestScopeErrorTypes(t *testing.T) {
+	result := runSource(t, "example.com/project", typesSrc)
+	result.ErrorTypes = result.ErrorTypeHierarchy()
+	result.Handlings = result.ErrorHandling()
+	require.NoError(t, result.Scope("project.NewDomainError"))
+
+	// the methods of DomainError are not reachable from NewDomainError but
+	// its mismatch is still reported.
+	require.Len(t, result.ErrorTypes, 1)
+	require.Equal(t, "example.com/project.DomainError", result.ErrorTypes[0].Type)
+	require.Len(t, result.ErrorTypes[0].Mismatches, 1)
+	require.Equal(t, "Is", result.ErrorTypes[0].Mismatches[0].Name)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteText(&buf, result))
+	require.Contains(t, buf.String(), "---DomainError.Is (value receiver, Error has a pointer receiver) \n")
+	require.NotContains(t, buf.String(), "MultiError")
+}
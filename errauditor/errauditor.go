@@ -4,21 +4,155 @@ import (
 	"fmt"
 	"go/ast"
 	"go/token"
-
-	"github.com/fatih/color"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 type ErrorType string
 
 type AggregatedError struct {
-	Func   string
-	Errors []string
+	Package           string
+	Recv              string
+	Func              string
+	Pos               token.Position
+	End               token.Position
+	Errors            []*ErrorEntry
+	WrappedErrorCount int64
+	ConstErrorCount   int64
+	// Constructions holds the errors returned through registered
+	// constructors, they are also rendered in Errors.
+	Constructions []*Construction
+	// Traces holds the errors of the function and the errors it returns
+	// through its calls, with their propagation. They are computed once the
+	// results are merged.
+	Traces []*TracedError
+	// Generated is set for functions declared in generated files.
+	Generated bool
+	// Test is set for functions declared in _test.go files.
+	Test bool
+}
+
+// ErrorKind classifies the errors returned by a function.
+type ErrorKind string
+
+const (
+	// CallError is an error returned by a call, such as
+	// `apperrors.ErrInternalServerError("done")`.
+	CallError ErrorKind = "call"
+	// WrappedError is an error wrapping another one, with fmt.Errorf and %w
+	// or a registered constructor taking a cause.
+	WrappedError ErrorKind = "wrapped"
+	// ConstError is a reference to a predeclared error value, such as
+	// `apperrors.ErrRecordNotFound`.
+	ConstError ErrorKind = "const"
+	// VarError is a variable wrapped by another error, holding the error of
+	// a call, such as `err` in `errors.Join(err, ErrClosed)`.
+	VarError ErrorKind = "var"
+)
+
+// ErrorEntry is an error returned by a function.
+type ErrorEntry struct {
+	// Pos is the position of the return statement.
+	Pos token.Position
+	// Expr is the returned expression.
+	Expr string
+	Kind ErrorKind
+	// Target is the qualified name of the declaration the expression calls or
+	// refers to, empty when it is not known, such as for methods of values.
+	Target string
+	// Text is the rendering of the entry in reports, such as
+	// `ErrInternalServerError("done",)`.
+	Text string
+	// Causes holds the errors wrapped by a wrapped error: the %w arguments of
+	// fmt.Errorf, which may be several since Go 1.20, or the arguments of
+	// errors.Join. The errors of wrapped variables are traced through the
+	// calls they are assigned.
+	Causes []*ErrorEntry
+}
+
+// String returns the rendering of the entry in reports.
+func (e *ErrorEntry) String() string {
+	return e.Text
+}
+
+// ErrorStrings returns the rendering of the errors of the function and of the
+// errors they wrap, except variables.
+func (a *AggregatedError) ErrorStrings() []string {
+	var errors []string
+	var add func(entries []*ErrorEntry)
+	add = func(entries []*ErrorEntry) {
+		for _, e := range entries {
+			if e.Kind == VarError {
+				continue
+			}
+			errors = append(errors, e.String())
+			add(e.Causes)
+		}
+	}
+	add(a.Errors)
+	return errors
+}
+
+// Name returns the function name qualified by its receiver type, using the
+// method expression syntax `T.M` or `(*T).M`.
+func (a *AggregatedError) Name() string {
+	switch {
+	case a.Recv == "":
+		return a.Func
+	case strings.HasPrefix(a.Recv, "*"):
+		return "(" + a.Recv + ")." + a.Func
+	default:
+		return a.Recv + "." + a.Func
+	}
+}
+
+// Key returns the qualified name of the function, like Decl.Key.
+func (a *AggregatedError) Key() string {
+	return declKey(a.Package, a.Recv, a.Func)
+}
+
+// IsExported reports whether the function is part of its package API.
+func (a *AggregatedError) IsExported() bool {
+	return token.IsExported(a.Func) && (a.Recv == "" || token.IsExported(strings.TrimPrefix(a.Recv, "*")))
+}
+
+// Finding is a rule violation reported against a function.
+type Finding struct {
+	Rule    string
+	Package string
+	Func    string
+	Pos     token.Position
+	Message string
 }
 
 type Result struct {
-	AggregatedErrors  []*AggregatedError
+	AggregatedErrors []*AggregatedError
+	Findings         []*Finding
+	// Definitions holds the positions of the package level functions and
+	// variables by qualified name, like ErrorEntry.Target.
+	Definitions       map[string]token.Position
 	WrappedErrorCount int64
 	ConstErrorCount   int64
+	// GeneratedFiles holds the audited generated files, findings inside them
+	// are suppressed.
+	GeneratedFiles map[string]bool
+	// Ignores holds the ignore directives of the audited files.
+	Ignores []*Ignore
+	// Decls holds the functions and package level variables by qualified
+	// name, with the calls they make.
+	Decls map[string]*Decl
+	// Constants holds the typed package level constants by qualified name.
+	Constants map[string]*Constant
+	// StatusMappings holds the HTTP status mapping methods.
+	StatusMappings []*StatusMapping
+	// EntryPoints holds the entry points the result is scoped to.
+	EntryPoints []*EntryPoint
+	// Handlings holds the handling of errors at call sites, when reported.
+	Handlings []*Handling
+	// ErrorTypes holds the types implementing error, when reported.
+	ErrorTypes []*CustomErrorType
 }
 
 const (
@@ -26,10 +160,6 @@ const (
 	Default ErrorType = "Default"
 )
 
-var (
-	result = Result{}
-)
-
 // ExtractFuncType extracts and returns the func returned type
 func ExtractFuncType(funcType *ast.FuncType) (ErrorType, int) {
 
@@ -67,77 +197,376 @@ func ExtarctArgFromExpr(expr []ast.Expr) string {
 	return argsConcat
 }
 
+// IsWrappedError reports whether expr wraps another error with fmt.Errorf and %w.
+func IsWrappedError(expr ast.Expr) bool {
+	callExpr, ok := expr.(*ast.CallExpr)
+	if !ok || len(callExpr.Args) == 0 {
+		return false
+	}
+	selExpr, ok := callExpr.Fun.(*ast.SelectorExpr)
+	if !ok || selExpr.Sel.Name != "Errorf" {
+		return false
+	}
+	format, ok := callExpr.Args[0].(*ast.BasicLit)
+	return ok && format.Kind == token.STRING && strings.Contains(format.Value, "%w")
+}
+
+// IsConstError reports whether expr refers to a predeclared error value such as
+// apperrors.ErrRecordNotFound.
+func IsConstError(expr ast.Expr) bool {
+	_, ok := expr.(*ast.SelectorExpr)
+	return ok
+}
+
 // ExtractReturnedErrorFromStmt extracts all instance of returned errors and string.
 func ExtractReturnedErrorFromStmt(etypePosIdx int, body *ast.BlockStmt, funcName string) *AggregatedError {
-	var errors []string
+	w := &walker{fset: token.NewFileSet()}
+	return w.returnedErrors(body, funcName, "", "")
+}
+
+// returnedErrors is ExtractReturnedErrorFromStmt reporting the calls to
+// declared and built-in constructors in their structured form, and resolving
+// the declarations the errors refer to.
+func (w *walker) returnedErrors(body *ast.BlockStmt, funcName, recv, recvName string) *AggregatedError {
 	agError := AggregatedError{
 		Func: funcName,
+		Recv: recv,
 	}
 	ast.Inspect(body, func(node ast.Node) bool {
 		if rtrnStmt, ok := node.(*ast.ReturnStmt); ok {
 			for _, expr := range rtrnStmt.Results {
-				var errorString string
-				// handle call expression or wrapped errors
-				if callExpr, ok := expr.(*ast.CallExpr); ok {
-					errorString = ReportSelFromExpr(
-						callExpr.Fun,
-						ExtarctArgFromExpr(callExpr.Args),
-					)
-				} else {
-					// handle selExpr
-					errorString = ReportSelFromExpr(expr, "")
+				entry, construction := w.errorEntry(expr, rtrnStmt.Pos(), recv, recvName)
+				if construction != nil {
+					agError.Constructions = append(agError.Constructions, construction)
 				}
-
-				if errorString != "" {
-					errors = append(errors, errorString)
+				if entry == nil {
+					continue
 				}
+				switch entry.Kind {
+				case WrappedError:
+					agError.WrappedErrorCount++
+				case ConstError:
+					agError.ConstErrorCount++
+				}
+				agError.Errors = append(agError.Errors, entry)
 			}
 		}
 		return true
 	})
-	if len(errors) > 0 {
-		agError.Errors = errors
+	if len(agError.Errors) > 0 {
 		return &agError
 	}
 	return nil
 }
 
-// WalkThroughExpr work through the file nodes
-func WalkThroughExpr(file *ast.File, fset *token.FileSet) {
-	var aggregatedErrors []*AggregatedError
+// errorEntry returns the entry of an error returned by the return statement at
+// pos, with the errors it wraps, and nil when the error is not rendered in
+// reports. The construction is returned for calls to registered constructors.
+func (w *walker) errorEntry(expr ast.Expr, pos token.Pos, recv, recvName string) (*ErrorEntry, *Construction) {
+	var errorString string
+	var construction *Construction
+	var causes []ast.Expr
+	// handle call expression or wrapped errors
+	if callExpr, ok := expr.(*ast.CallExpr); ok {
+		construction = w.construction(callExpr)
+		if construction != nil {
+			errorString = construction.String()
+		} else {
+			errorString = ReportSelFromExpr(
+				callExpr.Fun,
+				ExtarctArgFromExpr(callExpr.Args),
+			)
+		}
+		causes = w.causes(callExpr)
+	} else {
+		// handle selExpr
+		errorString = ReportSelFromExpr(expr, "")
+	}
+	if errorString == "" {
+		return nil, construction
+	}
+
+	entry := &ErrorEntry{
+		Pos:    w.fset.Position(pos),
+		Expr:   types.ExprString(expr),
+		Kind:   CallError,
+		Target: w.target(expr, recv, recvName),
+		Text:   errorString,
+	}
+	switch {
+	case IsWrappedError(expr) || len(causes) > 0 || (construction != nil && construction.Cause != ""):
+		entry.Kind = WrappedError
+	case IsConstError(expr):
+		entry.Kind = ConstError
+	}
+	for _, cause := range causes {
+		if c, _ := w.errorEntry(cause, pos, recv, recvName); c != nil {
+			entry.Causes = append(entry.Causes, c)
+		} else if ident, ok := cause.(*ast.Ident); ok {
+			entry.Causes = append(entry.Causes, &ErrorEntry{Pos: entry.Pos, Expr: ident.Name, Kind: VarError, Text: ident.Name})
+		}
+	}
+	return entry, construction
+}
+
+// causes returns the errors wrapped by a call: the cause of a constructor,
+// the arguments of the %w verbs of fmt.Errorf and the arguments of the
+// functions combining errors such as errors.Join. It returns nil for other
+// calls.
+func (w *walker) causes(call *ast.CallExpr) []ast.Expr {
+	if c := w.registry.lookup(w.pkgPath, w.imports, call.Fun); c != nil {
+		if c.Cause < 0 || c.Cause >= len(call.Args) {
+			return nil
+		}
+		return call.Args[c.Cause : c.Cause+1]
+	}
+	sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+	if !ok {
+		return nil
+	}
+	if x, ok := sel.X.(*ast.Ident); ok && joinFuncs[w.imports[x.Name]+"."+sel.Sel.Name] {
+		return call.Args
+	}
+	if !IsWrappedError(call) {
+		return nil
+	}
+	format, err := strconv.Unquote(call.Args[0].(*ast.BasicLit).Value)
+	if err != nil {
+		return nil
+	}
+	var causes []ast.Expr
+	for i, verb := range formatVerbs(format) {
+		if verb == 'w' && i+1 < len(call.Args) {
+			causes = append(causes, call.Args[i+1])
+		}
+	}
+	return causes
+}
+
+// formatVerbs returns the verb of each argument consumed by a format string,
+// with '*' for the widths and precisions taken from the arguments.
+func formatVerbs(format string) []byte {
+	var verbs []byte
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		for i++; i < len(format); i++ {
+			c := format[i]
+			if c == '*' {
+				verbs = append(verbs, c)
+				continue
+			}
+			if strings.IndexByte("+-# 0123456789.[]", c) >= 0 {
+				continue
+			}
+			if c != '%' {
+				verbs = append(verbs, c)
+			}
+			break
+		}
+	}
+	return verbs
+}
+
+// WalkThroughExpr work through the file nodes and returns the result for the file
+func WalkThroughExpr(pkgPath string, file *ast.File, fset *token.FileSet) *Result {
+	return walkFile(pkgPath, file, fset, nil)
+}
+
+// walkFile is WalkThroughExpr reporting the calls to the constructors of
+// registry and to the built-in constructors in their structured form.
+func walkFile(pkgPath string, file *ast.File, fset *token.FileSet, registry Registry) *Result {
+	result := &Result{}
+	imports := fileImports(file)
+	w := &walker{pkgPath: pkgPath, fset: fset, imports: imports, registry: registry}
+	generated := ast.IsGenerated(file)
+	if generated {
+		result.GeneratedFiles = map[string]bool{fset.Position(file.Pos()).Filename: true}
+	}
+	result.Ignores = parseIgnores(pkgPath, file, fset)
 	for _, d := range file.Decls {
-		if funcCall, ok := d.(*ast.FuncDecl); ok {
-			name := funcCall.Name.Name
-			returnedType, posIdx := ExtractFuncType(funcCall.Type)
-			posn := fset.Position(funcCall.Pos())
+		switch decl := d.(type) {
+		case *ast.FuncDecl:
+			name := decl.Name.Name
+			posn := fset.Position(decl.Pos())
+			if decl.Recv == nil {
+				result.addDefinition(declKey(pkgPath, "", name), posn)
+			}
+			if decl.Body != nil {
+				result.addDecl(w.funcDecl(decl))
+				if decl.Recv != nil && name == statusMethod {
+					if m := statusMapping(pkgPath, imports, decl, fset); m != nil {
+						result.StatusMappings = append(result.StatusMappings, m)
+					}
+				}
+			}
+			returnedType, posIdx := ExtractFuncType(decl.Type)
 
 			// check the returned type and position index
-			if returnedType == Error && posIdx != -1 {
-				agError := ExtractReturnedErrorFromStmt(posIdx, funcCall.Body, name)
+			if returnedType == Error && posIdx != -1 && decl.Body != nil {
+				var recv, recvName string
+				if decl.Recv != nil && len(decl.Recv.List) > 0 {
+					recv = recvTypeName(decl.Recv.List[0].Type)
+					if names := decl.Recv.List[0].Names; len(names) > 0 {
+						recvName = names[0].Name
+					}
+				}
+				agError := w.returnedErrors(decl.Body, name, recv, recvName)
 				if agError != nil {
+					agError.Package = pkgPath
+					agError.Pos = posn
+					agError.End = fset.Position(decl.End())
+					agError.Generated = generated
+					agError.Test = strings.HasSuffix(posn.Filename, "_test.go")
 					result.AggregatedErrors = append(result.AggregatedErrors, agError)
-					color.White("%s:  %s", posn, agError.Func)
-					for _, v := range agError.Errors {
-						color.Red("---%s ", v)
-					}
+					result.WrappedErrorCount += agError.WrappedErrorCount
+					result.ConstErrorCount += agError.ConstErrorCount
 				}
 			}
 			// ignore if func return type is not an error.
+		case *ast.GenDecl:
+			if decl.Tok == token.CONST {
+				result.addConstants(pkgPath, decl, fset)
+			}
+			if decl.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				spec := spec.(*ast.ValueSpec)
+				for i, ident := range spec.Names {
+					result.addDefinition(declKey(pkgPath, "", ident.Name), fset.Position(ident.Pos()))
+					if i < len(spec.Values) && len(spec.Values) == len(spec.Names) {
+						result.addDecl(w.varDecl(ident, spec.Type, spec.Values[i]))
+					}
+				}
+			}
+		}
+	}
+	return result
+}
+
+// recvTypeName returns the receiver type of a method without type parameters,
+// such as `usecase` or `*usecase`.
+func recvTypeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return "*" + recvTypeName(t.X)
+	case *ast.ParenExpr:
+		return recvTypeName(t.X)
+	case *ast.IndexExpr:
+		return recvTypeName(t.X)
+	case *ast.IndexListExpr:
+		return recvTypeName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// addDefinition records the position of a package level declaration so that
+// reported errors can be linked back to it. When a name is declared more than
+// once the first position in file order wins, regardless of the audit order.
+func (r *Result) addDefinition(key string, posn token.Position) {
+	if r.Definitions == nil {
+		r.Definitions = make(map[string]token.Position)
+	}
+	if prev, ok := r.Definitions[key]; !ok || positionLess(posn, prev) {
+		r.Definitions[key] = posn
+	}
+}
+
+func positionLess(x, y token.Position) bool {
+	if x.Filename != y.Filename {
+		return x.Filename < y.Filename
+	}
+	return x.Offset < y.Offset
+}
+
+// Merge adds the functions, findings, definitions, ignore directives and
+// declarations of other to the result.
+func (r *Result) Merge(other *Result) {
+	r.AggregatedErrors = append(r.AggregatedErrors, other.AggregatedErrors...)
+	r.Findings = append(r.Findings, other.Findings...)
+	r.Ignores = append(r.Ignores, other.Ignores...)
+	for _, d := range other.Decls {
+		r.addDecl(d)
+	}
+	for key, c := range other.Constants {
+		if r.Constants == nil {
+			r.Constants = make(map[string]*Constant)
 		}
+		r.Constants[key] = c
+	}
+	r.StatusMappings = append(r.StatusMappings, other.StatusMappings...)
+	for key, posn := range other.Definitions {
+		r.addDefinition(key, posn)
+	}
+	r.WrappedErrorCount += other.WrappedErrorCount
+	r.ConstErrorCount += other.ConstErrorCount
+	for filename := range other.GeneratedFiles {
+		if r.GeneratedFiles == nil {
+			r.GeneratedFiles = make(map[string]bool)
+		}
+		r.GeneratedFiles[filename] = true
 	}
-	result.AggregatedErrors = aggregatedErrors
 }
 
-func Run(f *ast.File, fset *token.FileSet) error {
+// addDecl records a declaration. When a name is declared more than once the
+// first position in file order wins, regardless of the audit order.
+func (r *Result) addDecl(d *Decl) {
+	if r.Decls == nil {
+		r.Decls = make(map[string]*Decl)
+	}
+	key := d.Key()
+	if prev, ok := r.Decls[key]; !ok || positionLess(d.Pos, prev.Pos) {
+		r.Decls[key] = d
+	}
+}
 
-	ast.Inspect(f, func(node ast.Node) bool {
-		switch n := node.(type) {
-		case *ast.File:
-			// walk though expression
-			WalkThroughExpr(n, fset)
+// suppressGenerated drops the findings inside generated files.
+func (r *Result) suppressGenerated() {
+	if len(r.GeneratedFiles) == 0 {
+		return
+	}
+	findings := r.Findings[:0]
+	for _, finding := range r.Findings {
+		if !r.GeneratedFiles[finding.Pos.Filename] {
+			findings = append(findings, finding)
 		}
+	}
+	r.Findings = findings
+}
 
-		return true
+// Sort orders functions and findings by package and position.
+func (r *Result) Sort() {
+	sort.SliceStable(r.AggregatedErrors, func(i, j int) bool {
+		x, y := r.AggregatedErrors[i], r.AggregatedErrors[j]
+		if x.Package != y.Package {
+			return x.Package < y.Package
+		}
+		return positionLess(x.Pos, y.Pos)
 	})
-	return nil
+	sort.SliceStable(r.Findings, func(i, j int) bool {
+		x, y := r.Findings[i], r.Findings[j]
+		if x.Package != y.Package {
+			return x.Package < y.Package
+		}
+		return positionLess(x.Pos, y.Pos)
+	})
+}
+
+// Definition returns the position of the declaration an error entry refers
+// to, given its qualified target such as
+// `example.com/project/pkg/apperrors.ErrInternalServerError`.
+func (r *Result) Definition(target string) (token.Position, bool) {
+	posn, ok := r.Definitions[target]
+	return posn, ok
+}
+
+func definitionName(entry string) string {
+	if i := strings.Index(entry, "("); i >= 0 {
+		return entry[:i]
+	}
+	return entry
 }
@@ -1 +1,79 @@
 package errauditor
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// runSource audits src as if it were the only file of package pkgPath.
+func runSource(t *testing.T, pkgPath, src string) *Result {
+	t.Helper()
+
+	auditor := NewAuditor()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "usecase.go", src, parser.ParseComments)
+	require.NoError(t, err)
+	require.NoError(t, auditor.Run(pkgPath, f, fset))
+	return auditor.Result()
+}
+
+const usecaseSrc = `package project
+
+import (
+	"errors"
+	"fmt"
+
+	"example.com/project/pkg/apperrors"
+)
+
+var ErrNotFound = errors.New("not found")
+
+func GetAddressByUser() error {
+	address := ""
+	err := errors.New("doe")
+	if address == "" {
+		return fmt.Errorf("unable to update appraisal by user: %w", err)
+	}
+	return apperrors.ErrRecordNotFound
+}
+
+func Count() int {
+	return 0
+}
+`
+
+func TestRun(t *testing.T) {
+	result := runSource(t, "example.com/project", usecaseSrc)
+
+	require.Len(t, result.AggregatedErrors, 1)
+	agError := result.AggregatedErrors[0]
+	require.Equal(t, "example.com/project", agError.Package)
+	require.Equal(t, "GetAddressByUser", agError.Func)
+	require.Equal(t, 12, agError.Pos.Line)
+	require.Equal(t, []string{
+		`Errorf("unable to update appraisal by user: %w",)`,
+		`ErrRecordNotFound()`,
+	}, agError.ErrorStrings())
+	wrapped, constant := agError.Errors[0], agError.Errors[1]
+	require.Equal(t, 16, wrapped.Pos.Line)
+	require.Equal(t, `fmt.Errorf("unable to update appraisal by user: %w", err)`, wrapped.Expr)
+	require.Equal(t, WrappedError, wrapped.Kind)
+	require.Equal(t, "fmt.Errorf", wrapped.Target)
+	require.Equal(t, &ErrorEntry{
+		Pos:    constant.Pos,
+		Expr:   "apperrors.ErrRecordNotFound",
+		Kind:   ConstError,
+		Target: "example.com/project/pkg/apperrors.ErrRecordNotFound",
+		Text:   "ErrRecordNotFound()",
+	}, constant)
+	require.Equal(t, 18, constant.Pos.Line)
+	require.EqualValues(t, 1, result.WrappedErrorCount)
+	require.EqualValues(t, 1, result.ConstErrorCount)
+
+	posn, ok := result.Definition("example.com/project.ErrNotFound")
+	require.True(t, ok)
+	require.Equal(t, 10, posn.Line)
+}
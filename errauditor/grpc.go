@@ -0,0 +1,291 @@
+package errauditor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	grpcStatusPackage = "google.golang.org/grpc/status"
+	grpcCodesPackage  = "google.golang.org/grpc/codes"
+)
+
+// grpcCodeOrder holds the gRPC codes in the order of their values.
+var grpcCodeOrder = []string{
+	"OK", "Canceled", "Unknown", "InvalidArgument", "DeadlineExceeded", "NotFound",
+	"AlreadyExists", "PermissionDenied", "ResourceExhausted", "FailedPrecondition",
+	"Aborted", "OutOfRange", "Unimplemented", "Internal", "Unavailable", "DataLoss",
+	"Unauthenticated",
+}
+
+// RPCCodes is an RPC method with the gRPC codes it may return.
+type RPCCodes struct {
+	// Method is the method qualified by its package name and receiver type.
+	Method string   `json:"method"`
+	Pos    string   `json:"pos"`
+	Codes  []string `json:"codes"`
+}
+
+// grpcMapping is a function translating domain errors to gRPC status errors,
+// evaluated statically from the guards of its calls producing a code.
+type grpcMapping struct {
+	decl *Decl
+	// codes maps the qualified names of the domain codes or errors the code is
+	// guarded by to the code.
+	codes map[string]string
+	// fallback is the code produced without a guard, such as by the default
+	// clause.
+	fallback string
+	// domain holds the constants of the domain code type by name, nil when
+	// the mapping is guarded by errors.
+	domain map[string]*Constant
+}
+
+// grpcCode returns the gRPC code produced by a call to status.Error or
+// status.Errorf, or by a reference to a code returned by the caller, and an
+// empty string for other calls.
+func grpcCode(call *Call) string {
+	if c := call.Construction; c != nil && strings.HasPrefix(c.Constructor, grpcStatusPackage+".") && c.Code != "" {
+		return c.Code[strings.LastIndex(c.Code, ".")+1:]
+	}
+	if call.Package == grpcCodesPackage && call.Returned && !call.Method {
+		return call.Name
+	}
+	return ""
+}
+
+// newGRPCMapping evaluates a mapping function. Its guards must all be
+// constants of the same domain code type, or all be errors.
+func (r *Result) newGRPCMapping(d *Decl) (*grpcMapping, error) {
+	m := &grpcMapping{decl: d, codes: make(map[string]string)}
+	// kinds holds the type of the guards, "error" for errors, and the first
+	// guard of each.
+	kinds := make(map[string]string)
+	var typ string
+	for _, call := range d.Calls {
+		code := grpcCode(call)
+		if code == "" {
+			continue
+		}
+		if len(call.Guards) == 0 {
+			m.fallback = code
+			continue
+		}
+		for _, guard := range call.Guards {
+			if _, ok := m.codes[guard]; !ok {
+				m.codes[guard] = code
+			}
+			kind := ""
+			if c, ok := r.Constants[guard]; ok {
+				kind = c.Type
+				typ = c.Type
+			} else if _, ok := r.Decls[guard]; ok {
+				kind = "error"
+			}
+			if _, ok := kinds[kind]; kind != "" && !ok {
+				kinds[kind] = guard
+			}
+		}
+	}
+	if len(kinds) > 1 {
+		var guards []string
+		for _, guard := range kinds {
+			guards = append(guards, shortName(guard))
+		}
+		sort.Strings(guards)
+		return nil, fmt.Errorf("mapping %s is guarded by values of different types: %s", shortName(d.Key()), strings.Join(guards, ", "))
+	}
+	if typ != "" {
+		m.domain = r.typeConstants(typ)
+	}
+	return m, nil
+}
+
+// grpcAudit computes the gRPC codes returned by declarations.
+type grpcAudit struct {
+	graph    *callGraph
+	agErrors map[string]*AggregatedError
+	mappings map[*Decl]*grpcMapping
+	sets     map[*grpcMapping]*codeSets
+	memo     map[string]map[string]bool
+}
+
+// returned returns the codes of the errors a declaration returns.
+func (a *grpcAudit) returned(d *Decl) map[string]bool {
+	key := d.Key()
+	if set, ok := a.memo[key]; ok {
+		return set
+	}
+	set := make(map[string]bool)
+	// recursive declarations see their partial set.
+	a.memo[key] = set
+	for _, call := range d.Calls {
+		if !call.Returned {
+			continue
+		}
+		if code := grpcCode(call); code != "" {
+			set[code] = true
+			continue
+		}
+		callee := a.graph.resolve(call)
+		switch {
+		case callee == nil:
+		case a.mappings[callee] != nil:
+			a.mapped(set, d, a.mappings[callee])
+		case callee.Error:
+			for code := range a.returned(callee) {
+				set[code] = true
+			}
+		}
+	}
+	if agError := a.agErrors[key]; agError != nil {
+		for _, e := range agError.Errors {
+			a.unknown(set, e)
+		}
+	}
+	return set
+}
+
+// mapped adds the codes a mapping translates the errors of the calls of d to.
+func (a *grpcAudit) mapped(set map[string]bool, d *Decl, m *grpcMapping) {
+	for _, call := range d.Calls {
+		callee := a.graph.resolve(call)
+		if callee == nil || callee == m.decl || !callee.Error {
+			continue
+		}
+		matched, unmatched := false, false
+		if m.domain != nil {
+			for code := range a.sets[m].returned(callee) {
+				matched = true
+				if mapped, ok := m.codes[m.domain[code].Package+"."+code]; ok {
+					set[mapped] = true
+				} else {
+					unmatched = true
+				}
+			}
+			unmatched = unmatched || !matched
+		} else if agError := a.agErrors[callee.Key()]; agError != nil {
+			for _, e := range agError.traced() {
+				if e.Entry != nil && e.Entry.Kind == ConstError {
+					if mapped, ok := m.codes[e.Entry.Target]; ok {
+						set[mapped] = true
+						continue
+					}
+				}
+				unmatched = true
+			}
+		}
+		if unmatched && m.fallback != "" {
+			set[m.fallback] = true
+		}
+	}
+}
+
+// unknown adds the Unknown code when a returned error is not a status error,
+// such as a package level error or an error constructed outside the audit.
+// The errors of wrapped variables, audited functions and methods of values
+// are followed through the calls of the function.
+func (a *grpcAudit) unknown(set map[string]bool, e *ErrorEntry) {
+	switch e.Kind {
+	case WrappedError:
+		for _, cause := range e.Causes {
+			a.unknown(set, cause)
+		}
+	case ConstError:
+		set["Unknown"] = true
+	case CallError:
+		if e.Target == "" || strings.HasPrefix(e.Target, grpcStatusPackage+".") {
+			return
+		}
+		if d, ok := a.graph.decls[e.Target]; ok && (d.Error || a.mappings[d] != nil) {
+			return
+		}
+		set["Unknown"] = true
+	}
+}
+
+// GRPCCodes reports, for each gRPC service method, the codes of the status
+// errors it may return. The domain errors translated by the mapping
+// functions, named like in Lookup, get the codes of the mapping. Errors that
+// are not status errors get the Unknown code.
+func (r *Result) GRPCCodes(mappings ...string) ([]*RPCCodes, error) {
+	graph := newCallGraph(r.Decls)
+	a := &grpcAudit{
+		graph:    graph,
+		agErrors: r.aggregatedErrors(),
+		mappings: make(map[*Decl]*grpcMapping),
+		sets:     make(map[*grpcMapping]*codeSets),
+		memo:     make(map[string]map[string]bool),
+	}
+	for _, name := range mappings {
+		decls := r.Lookup(name)
+		if len(decls) == 0 {
+			return nil, fmt.Errorf("mapping %s not found", name)
+		}
+		for _, d := range decls {
+			m, err := r.newGRPCMapping(d)
+			if err != nil {
+				return nil, err
+			}
+			a.mappings[d] = m
+			a.sets[m] = &codeSets{graph: graph, codes: m.domain, memo: make(map[string]map[string]bool)}
+		}
+	}
+
+	var rpcs []*RPCCodes
+	for _, d := range r.Decls {
+		if !d.RPC {
+			continue
+		}
+		rpc := &RPCCodes{Method: shortName(d.Key()), Pos: d.Pos.String(), Codes: []string{}}
+		for code := range a.returned(d) {
+			rpc.Codes = append(rpc.Codes, code)
+		}
+		sortGRPCCodes(rpc.Codes)
+		rpcs = append(rpcs, rpc)
+	}
+	sort.Slice(rpcs, func(i, j int) bool {
+		return rpcs[i].Method < rpcs[j].Method
+	})
+	return rpcs, nil
+}
+
+// sortGRPCCodes orders codes by value, unknown names last.
+func sortGRPCCodes(codes []string) {
+	index := func(code string) int {
+		for i, c := range grpcCodeOrder {
+			if c == code {
+				return i
+			}
+		}
+		return len(grpcCodeOrder)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		x, y := index(codes[i]), index(codes[j])
+		if x != y {
+			return x < y
+		}
+		return codes[i] < codes[j]
+	})
+}
+
+// WriteGRPCCodes writes the codes of each RPC method as a table.
+func WriteGRPCCodes(w io.Writer, rpcs []*RPCCodes) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "METHOD\tSOURCE\tCODES\n")
+	for _, rpc := range rpcs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", rpc.Method, rpc.Pos, strings.Join(rpc.Codes, ", "))
+	}
+	return tw.Flush()
+}
+
+// WriteGRPCCodesJSON writes the codes of each RPC method as indented JSON.
+func WriteGRPCCodesJSON(w io.Writer, rpcs []*RPCCodes) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rpcs)
+}
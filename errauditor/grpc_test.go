@@ -0,0 +1,193 @@
+package errauditor
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const serverSrc = `package project
+
+import (
+	"context"
+	"errors"
+
+	"example.com/project/pkg/apperrors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type server struct {
+	uc *usecase
+}
+
+func (s *server) GetUser(ctx context.Context, req *GetUserRequest) (*GetUserResponse, error) {
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing id")
+	}
+	user, err := s.uc.Get(req.Id)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &GetUserResponse{Name: user}, nil
+}
+
+func (s *server) FindUser(ctx context.Context, req *FindUserRequest) (*FindUserResponse, error) {
+	_, err := s.uc.repo.Find(req.Id)
+	return nil, fromError(err)
+}
+
+func (s *server) DeleteUser(ctx context.Context, req *DeleteUserRequest) (*DeleteUserResponse, error) {
+	if err := s.uc.Delete(req.Id); err != nil {
+		return nil, err
+	}
+	return &DeleteUserResponse{}, nil
+}
+
+func (s *server) Watch(req *WatchRequest, stream Users_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "watch %s", req.Id)
+}
+
+func (u *usecase) Delete(id string) error {
+	return apperrors.ErrRecordNotFound
+}
+
+func toStatus(err error) error {
+	var de *apperrors.DomainError
+	if errors.As(err, &de) {
+		switch de.Code {
+		case apperrors.CodeRecordNotFound:
+			return status.Error(codes.NotFound, de.Message)
+		case apperrors.CodeUnauthorized, apperrors.CodeSessionExpired:
+			return status.Error(codes.PermissionDenied, de.Message)
+		}
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+func fromError(err error) error {
+	switch {
+	case errors.Is(err, apperrors.ErrRecordNotFound):
+		return status.Error(codes.NotFound, "not found")
+	}
+	return status.Error(codes.Unavailable, err.Error())
+}
+`
+
+func TestGRPCCodes(t *testing.T) {
+	auditor := NewAuditor()
+	fset := token.NewFileSet()
+	for _, file := range []struct{ pkgPath, name, src string }{
+		{"example.com/project/pkg/apperrors", "errors.go", apperrorsSrc},
+		{"example.com/project", "handler.go", handlerSrc},
+		{"example.com/project", "server.go", serverSrc},
+	} {
+		f, err := parser.ParseFile(fset, file.name, file.src, 0)
+		require.NoError(t, err)
+		require.NoError(t, auditor.Run(file.pkgPath, f, fset))
+	}
+	result := auditor.Result()
+
+	_, err := result.GRPCCodes("project.missing")
+	require.Error(t, err)
+
+	rpcs, err := result.GRPCCodes("project.toStatus", "project.fromError")
+	require.NoError(t, err)
+	codes := make(map[string][]string)
+	for _, rpc := range rpcs {
+		codes[rpc.Method] = rpc.Codes
+	}
+	require.Equal(t, map[string][]string{
+		"project.(*server).GetUser":    {"InvalidArgument", "NotFound", "Internal"},
+		"project.(*server).FindUser":   {"NotFound"},
+		"project.(*server).DeleteUser": {"Unknown"},
+		"project.(*server).Watch":      {"Unimplemented"},
+	}, codes)
+
+	// without the mappings all the codes of toStatus are returned.
+	rpcs, err = result.GRPCCodes()
+	require.NoError(t, err)
+	require.Equal(t, "project.(*server).GetUser", rpcs[2].Method)
+	require.Equal(t, []string{"InvalidArgument", "NotFound", "PermissionDenied", "Internal"}, rpcs[2].Codes)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteGRPCCodes(&buf, rpcs))
+	require.Contains(t, buf.String(), "project.(*server).Watch       server.go:39:1  Unimplemented\n")
+}
+
+const guardsSrc = `package project
+
+import (
+	"context"
+	"errors"
+
+	"example.com/project/pkg/apperrors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var ErrRecordNotFound = errors.New("local record not found")
+
+type server struct {
+	uc *usecase
+}
+
+func (s *server) DeleteUser(ctx context.Context, req *DeleteUserRequest) (*DeleteUserResponse, error) {
+	return nil, fromError(s.uc.Delete(req.Id))
+}
+
+func (u *usecase) Delete(id string) error {
+	return apperrors.ErrRecordNotFound
+}
+
+func fromError(err error) error {
+	if errors.Is(err, ErrRecordNotFound) {
+		return status.Error(codes.Aborted, "local")
+	}
+	if errors.Is(err, apperrors.ErrRecordNotFound) {
+		return status.Error(codes.NotFound, "not found")
+	}
+	return status.Error(codes.Unavailable, err.Error())
+}
+
+func mixed(err error) error {
+	var de *apperrors.DomainError
+	if errors.As(err, &de) {
+		switch de.Code {
+		case apperrors.CodeRecordNotFound:
+			return status.Error(codes.NotFound, de.Message)
+		}
+	}
+	if errors.Is(err, apperrors.ErrRecordNotFound) {
+		return status.Error(codes.NotFound, "not found")
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+`
+
+func TestGRPCMappingGuards(t *testing.T) {
+	auditor := NewAuditor()
+	fset := token.NewFileSet()
+	for _, file := range []struct{ pkgPath, name, src string }{
+		{"example.com/project/pkg/apperrors", "errors.go", apperrorsSrc},
+		{"example.com/project", "server.go", guardsSrc},
+	} {
+		f, err := parser.ParseFile(fset, file.name, file.src, 0)
+		require.NoError(t, err)
+		require.NoError(t, auditor.Run(file.pkgPath, f, fset))
+	}
+	result := auditor.Result()
+
+	// the local error of the same name does not take over the code of
+	// apperrors.ErrRecordNotFound.
+	rpcs, err := result.GRPCCodes("project.fromError")
+	require.NoError(t, err)
+	require.Len(t, rpcs, 1)
+	require.Equal(t, []string{"NotFound"}, rpcs[0].Codes)
+
+	_, err = result.GRPCCodes("project.mixed")
+	require.EqualError(t, err, "mapping project.mixed is guarded by values of different types: apperrors.CodeRecordNotFound, apperrors.ErrRecordNotFound")
+}
@@ -0,0 +1,405 @@
+package errauditor
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+)
+
+// DeadCheckRule is reported for checks against errors the called function
+// never returns.
+const DeadCheckRule = "dead-error-check"
+
+// WrappedCompareRule is reported for errors compared with == or a switch
+// against errors the called function returns wrapped.
+const WrappedCompareRule = "wrapped-error-comparison"
+
+// CheckKind is the way the error returned by a call is checked.
+type CheckKind string
+
+const (
+	IsCheck         CheckKind = "errors.Is"
+	AsCheck         CheckKind = "errors.As"
+	EqualCheck      CheckKind = "=="
+	SwitchCheck     CheckKind = "switch"
+	TypeSwitchCheck CheckKind = "type switch"
+)
+
+// errorsPackages are the packages providing errors.Is and errors.As.
+var errorsPackages = map[string]bool{
+	"errors":                        true,
+	"github.com/pkg/errors":         true,
+	"github.com/cockroachdb/errors": true,
+}
+
+// Check is a check of the error returned by a call.
+type Check struct {
+	Pos  token.Position
+	Kind CheckKind
+	// Target is the qualified name of the error value or of the error type
+	// checked against, such as `example.com/project/pkg/apperrors.ErrRecordNotFound`
+	// or `*example.com/project/pkg/apperrors.DomainError`.
+	Target string
+}
+
+// boundCheck is a check of a variable, bound to the call assigned to the
+// variable last before the check.
+type boundCheck struct {
+	*Check
+	pos token.Pos
+}
+
+// checks returns the checks made in node by checked variable.
+func (w *walker) checks(node ast.Node) map[string][]*boundCheck {
+	// the types of local variables, the targets of errors.As.
+	varTypes := make(map[string]ast.Expr)
+	ast.Inspect(node, func(node ast.Node) bool {
+		if spec, ok := node.(*ast.ValueSpec); ok && spec.Type != nil {
+			for _, name := range spec.Names {
+				varTypes[name.Name] = spec.Type
+			}
+		}
+		return true
+	})
+
+	checks := make(map[string][]*boundCheck)
+	add := func(x ast.Expr, pos token.Pos, kind CheckKind, target string) {
+		ident, ok := ast.Unparen(x).(*ast.Ident)
+		if !ok || target == "" {
+			return
+		}
+		checks[ident.Name] = append(checks[ident.Name], &boundCheck{
+			Check: &Check{Pos: w.fset.Position(pos), Kind: kind, Target: target},
+			pos:   pos,
+		})
+	}
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.CallExpr:
+			if len(node.Args) != 2 {
+				return true
+			}
+			switch w.errorsFunc(node) {
+			case "Is":
+				add(node.Args[0], node.Pos(), IsCheck, w.value(node.Args[1]))
+			case "As":
+				target := ast.Unparen(node.Args[1])
+				if u, ok := target.(*ast.UnaryExpr); ok && u.Op == token.AND {
+					target = u.X
+				}
+				if ident, ok := target.(*ast.Ident); ok && varTypes[ident.Name] != nil {
+					add(node.Args[0], node.Pos(), AsCheck, w.qualify(varTypes[ident.Name]))
+				}
+			}
+		case *ast.BinaryExpr:
+			if node.Op == token.EQL || node.Op == token.NEQ {
+				add(node.X, node.Pos(), EqualCheck, w.value(node.Y))
+				add(node.Y, node.Pos(), EqualCheck, w.value(node.X))
+			}
+		case *ast.SwitchStmt:
+			if node.Tag == nil {
+				return true
+			}
+			for _, stmt := range node.Body.List {
+				for _, expr := range stmt.(*ast.CaseClause).List {
+					add(node.Tag, expr.Pos(), SwitchCheck, w.value(expr))
+				}
+			}
+		case *ast.TypeSwitchStmt:
+			var x ast.Expr
+			switch assign := node.Assign.(type) {
+			case *ast.AssignStmt:
+				x = assign.Rhs[0]
+			case *ast.ExprStmt:
+				x = assign.X
+			}
+			assert, ok := x.(*ast.TypeAssertExpr)
+			if !ok {
+				return true
+			}
+			for _, stmt := range node.Body.List {
+				for _, expr := range stmt.(*ast.CaseClause).List {
+					if ident, ok := expr.(*ast.Ident); !ok || ident.Name != "nil" {
+						add(assert.X, expr.Pos(), TypeSwitchCheck, w.qualify(expr))
+					}
+				}
+			}
+		}
+		return true
+	})
+	return checks
+}
+
+// errorsFunc returns the name of the function of an errors package a call
+// calls, such as `Is`, and an empty string for other calls.
+func (w *walker) errorsFunc(call *ast.CallExpr) string {
+	sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+	if !ok {
+		return ""
+	}
+	if x, ok := sel.X.(*ast.Ident); !ok || !errorsPackages[w.imports[x.Name]] {
+		return ""
+	}
+	return sel.Sel.Name
+}
+
+// guard is a part of a function executed only for some values.
+type guard struct {
+	pos, end token.Pos
+	values   []string
+}
+
+// guards returns the case clauses of node with the qualified values they
+// list, and the bodies of the if statements of node with the errors they
+// check with errors.Is or ==.
+func (w *walker) guards(node ast.Node) []*guard {
+	var guards []*guard
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.CaseClause:
+			g := &guard{pos: node.Colon, end: node.End()}
+			for _, expr := range node.List {
+				if v := w.checked(expr); v != "" {
+					g.values = append(g.values, v)
+				} else if v := w.value(expr); v != "" {
+					g.values = append(g.values, v)
+				}
+			}
+			if len(g.values) > 0 {
+				guards = append(guards, g)
+			}
+		case *ast.IfStmt:
+			if v := w.checked(node.Cond); v != "" {
+				guards = append(guards, &guard{pos: node.Body.Pos(), end: node.Body.End(), values: []string{v}})
+			}
+		}
+		return true
+	})
+	return guards
+}
+
+// checked returns the qualified name of the value an error is checked
+// against by a condition, `errors.Is(err, ErrX)` or `err == ErrX`.
+func (w *walker) checked(cond ast.Expr) string {
+	switch cond := ast.Unparen(cond).(type) {
+	case *ast.CallExpr:
+		if len(cond.Args) == 2 && w.errorsFunc(cond) == "Is" {
+			return w.value(cond.Args[1])
+		}
+	case *ast.BinaryExpr:
+		if cond.Op == token.EQL {
+			if v := w.value(cond.Y); v != "" {
+				return v
+			}
+			return w.value(cond.X)
+		}
+	}
+	return ""
+}
+
+// guardsAt returns the values guarding pos.
+func guardsAt(guards []*guard, pos token.Pos) []string {
+	var values []string
+	for _, g := range guards {
+		if g.pos <= pos && pos < g.end {
+			values = append(values, g.values...)
+		}
+	}
+	return values
+}
+
+// value returns the qualified name of a package level value, and an empty
+// string for other expressions.
+func (w *walker) value(expr ast.Expr) string {
+	switch expr := ast.Unparen(expr).(type) {
+	case *ast.Ident:
+		if types.Universe.Lookup(expr.Name) != nil {
+			return ""
+		}
+		return w.pkgPath + "." + expr.Name
+	case *ast.SelectorExpr:
+		if x, ok := expr.X.(*ast.Ident); ok && w.imports[x.Name] != "" {
+			return w.imports[x.Name] + "." + expr.Sel.Name
+		}
+	}
+	return ""
+}
+
+// Handling is the handling of the errors returned by a call whose error is
+// not just returned.
+type Handling struct {
+	// Caller and Callee are qualified names.
+	Caller string
+	Callee string
+	Pos    token.Position
+	Checks []*Check
+	// Checked holds the errors of the callee matched by a check.
+	Checked []*CheckedError
+	// Generic holds the errors of the callee handled only generically.
+	Generic []string
+	// Dead holds the checks against errors the callee never returns.
+	Dead []*Check
+	// Wrapped holds the == and switch checks against errors the callee
+	// returns wrapped, which they do not match.
+	Wrapped []*Check
+}
+
+// CheckedError is an error of a callee matched by a check.
+type CheckedError struct {
+	Error string
+	Check *Check
+}
+
+// ErrorHandling reports, for each call to a function with a known error set
+// whose error is checked or not returned, which of the errors the caller
+// checks and which it handles only generically.
+func (r *Result) ErrorHandling() []*Handling {
+	graph := newCallGraph(r.Decls)
+	agErrors := r.aggregatedErrors()
+	var handlings []*Handling
+	for _, d := range r.Decls {
+		for _, call := range d.Calls {
+			if call.Returned && len(call.Checks) == 0 {
+				continue
+			}
+			callee := graph.resolve(call)
+			if callee == nil || !callee.Error || agErrors[callee.Key()] == nil {
+				continue
+			}
+			traced := agErrors[callee.Key()].traced()
+			if len(traced) == 0 {
+				continue
+			}
+			handlings = append(handlings, graph.handling(d, callee, call, traced))
+		}
+	}
+	sort.Slice(handlings, func(i, j int) bool {
+		return positionLess(handlings[i].Pos, handlings[j].Pos)
+	})
+	return handlings
+}
+
+// handling matches the checks of a call against the errors of its callee.
+func (g *callGraph) handling(caller, callee *Decl, call *Call, traced []*TracedError) *Handling {
+	h := &Handling{Caller: caller.Key(), Callee: callee.Key(), Pos: call.Pos, Checks: call.Checks}
+	// opaque is set when an error of the callee may be anything.
+	opaque := false
+	matched := make([]bool, len(call.Checks))
+	wrapped := make([]bool, len(call.Checks))
+	for _, e := range traced {
+		if e.Entry == nil || g.opaque(e.Entry) {
+			opaque = true
+		}
+		var check *Check
+		for i, c := range call.Checks {
+			if e.Entry != nil && g.matches(e.Entry, c) {
+				matched[i] = true
+				wrapped[i] = wrapped[i] || e.Wrapped && (c.Kind == EqualCheck || c.Kind == SwitchCheck)
+				if check == nil {
+					check = c
+				}
+			}
+		}
+		if check != nil {
+			h.Checked = append(h.Checked, &CheckedError{Error: e.Error, Check: check})
+		} else {
+			h.Generic = append(h.Generic, e.Error)
+		}
+	}
+	for i, c := range call.Checks {
+		if wrapped[i] {
+			h.Wrapped = append(h.Wrapped, c)
+		}
+	}
+	if opaque {
+		return h
+	}
+	for i, c := range call.Checks {
+		if !matched[i] && g.decidable(c) {
+			h.Dead = append(h.Dead, c)
+		}
+	}
+	return h
+}
+
+// opaque reports whether an error may be of any type and value, such as the
+// error of a function outside the audit.
+func (g *callGraph) opaque(e *ErrorEntry) bool {
+	if e.Kind != CallError {
+		return false
+	}
+	d, ok := g.decls[e.Target]
+	return !ok || d.Type == "error"
+}
+
+// matches reports whether an error can satisfy a check. Wrapped errors match
+// the errors they wrap, the errors they wrap through variables are traced on
+// their own.
+func (g *callGraph) matches(e *ErrorEntry, c *Check) bool {
+	for _, cause := range e.Causes {
+		if g.matches(cause, c) {
+			return true
+		}
+	}
+	switch c.Kind {
+	case AsCheck, TypeSwitchCheck:
+		return e.Kind != WrappedError && g.errorType(e) == c.Target
+	}
+	return e.Kind == ConstError && e.Target == c.Target
+}
+
+// decidable reports whether a check that matches no error is dead: checks
+// against values declared outside the audit are not.
+func (g *callGraph) decidable(c *Check) bool {
+	switch c.Kind {
+	case AsCheck, TypeSwitchCheck:
+		return true
+	}
+	d, ok := g.decls[c.Target]
+	return ok && d.Recv == "" && !d.Error
+}
+
+// errorType returns the qualified type of an error, empty when it is unknown.
+func (g *callGraph) errorType(e *ErrorEntry) string {
+	d, ok := g.decls[e.Target]
+	if !ok {
+		return ""
+	}
+	if d.Type == "" && e.Kind == ConstError {
+		// variables initialized by a constructor, `var ErrX = NewError(...)`.
+		for _, call := range d.Calls {
+			if callee := g.resolve(call); call.Returned && callee != nil {
+				return callee.Type
+			}
+		}
+	}
+	return d.Type
+}
+
+// checkErrorHandling reports the checks against errors the called function
+// never returns, and the comparisons with errors it returns wrapped.
+func (r *Result) checkErrorHandling() {
+	for _, h := range r.ErrorHandling() {
+		caller := r.Decls[h.Caller]
+		for _, c := range h.Dead {
+			r.Findings = append(r.Findings, &Finding{
+				Rule:    DeadCheckRule,
+				Package: caller.Package,
+				Func:    (&AggregatedError{Recv: caller.Recv, Func: caller.Name}).Name(),
+				Pos:     c.Pos,
+				Message: fmt.Sprintf("%s checks %s which %s never returns", c.Kind, shortName(c.Target), shortName(h.Callee)),
+			})
+		}
+		for _, c := range h.Wrapped {
+			r.Findings = append(r.Findings, &Finding{
+				Rule:    WrappedCompareRule,
+				Package: caller.Package,
+				Func:    (&AggregatedError{Recv: caller.Recv, Func: caller.Name}).Name(),
+				Pos:     c.Pos,
+				Message: fmt.Sprintf("%s compares with %s which %s returns wrapped, use errors.Is", c.Kind, shortName(c.Target), shortName(h.Callee)),
+			})
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package errauditor
+
+import (
+	"bytes"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const handleSrc = `package project
+
+import (
+	"errors"
+	"net/http"
+
+	"example.com/project/pkg/apperrors"
+)
+
+var ErrLocal = errors.New("local")
+
+func (u *usecase) Handle(w http.ResponseWriter, r *http.Request) {
+	_, err := u.Get("id")
+	if errors.Is(err, apperrors.ErrRecordNotFound) {
+		return
+	}
+	var de *apperrors.DomainError
+	if errors.As(err, &de) {
+		return
+	}
+	if err == ErrLocal {
+		return
+	}
+	_, err = u.repo.Find("id")
+	switch err {
+	case apperrors.ErrRecordNotFound:
+	}
+}
+`
+
+func TestErrorHandling(t *testing.T) {
+	auditor := NewAuditor()
+	fset := token.NewFileSet()
+	for _, file := range []struct{ pkgPath, name, src string }{
+		{"example.com/project/pkg/apperrors", "errors.go", apperrorsSrc},
+		{"example.com/project", "handler.go", handlerSrc},
+		{"example.com/project", "handle.go", handleSrc},
+	} {
+		f, err := parser.ParseFile(fset, file.name, file.src, 0)
+		require.NoError(t, err)
+		require.NoError(t, auditor.Run(file.pkgPath, f, fset))
+	}
+	result := auditor.Result()
+
+	var handlings []*Handling
+	for _, h := range result.ErrorHandling() {
+		if h.Caller == "example.com/project.(*usecase).Handle" {
+			handlings = append(handlings, h)
+		}
+	}
+	require.Len(t, handlings, 2)
+
+	get := handlings[0]
+	require.Equal(t, "example.com/project.(*usecase).Get", get.Callee)
+	require.Len(t, get.Checks, 3)
+	require.Equal(t, "*example.com/project/pkg/apperrors.DomainError", get.Checks[1].Target)
+	require.Len(t, get.Checked, 2)
+	require.Equal(t, `ErrInternalServerError("admin",)`, get.Checked[0].Error)
+	require.Equal(t, AsCheck, get.Checked[0].Check.Kind)
+	require.Equal(t, "ErrRecordNotFound()", get.Checked[1].Error)
+	require.Equal(t, IsCheck, get.Checked[1].Check.Kind)
+	require.Empty(t, get.Generic)
+	require.Len(t, get.Dead, 1)
+	require.Equal(t, "example.com/project.ErrLocal", get.Dead[0].Target)
+
+	find := handlings[1]
+	require.Equal(t, "example.com/project.(*repo).Find", find.Callee)
+	require.Equal(t, SwitchCheck, find.Checked[0].Check.Kind)
+	require.Empty(t, find.Dead)
+
+	// the handler only passes the error of Get to writeError.
+	for _, h := range result.ErrorHandling() {
+		if h.Caller == "example.com/project.(*usecase).GetHandler" && h.Callee == get.Callee {
+			require.Empty(t, h.Checked)
+			require.Equal(t, []string{`ErrInternalServerError("admin",)`, "ErrRecordNotFound()"}, h.Generic)
+		}
+	}
+
+	// both handlers also receive CodeInternalError, which is not mapped.
+	require.Len(t, result.Findings, 3)
+	require.Equal(t, HTTPStatusRule, result.Findings[0].Rule)
+	dead := result.Findings[1]
+	require.Equal(t, DeadCheckRule, dead.Rule)
+	require.Equal(t, "(*usecase).Handle", dead.Func)
+	require.Equal(t, 21, dead.Pos.Line)
+	require.Equal(t, "== checks project.ErrLocal which project.(*usecase).Get never returns", dead.Message)
+
+	result.Handlings = handlings
+	var buf bytes.Buffer
+	require.NoError(t, WriteMarkdown(&buf, result))
+	require.Contains(t, buf.String(), "| `project.(*usecase).Handle` | `project.(*usecase).Get` | handle.go:13:12 | `ErrInternalServerError(\"admin\",)` (errors.As)<br>`ErrRecordNotFound()` (errors.Is) |  |")
+}
+
+const syncSrc = `package project
+
+import (
+	"fmt"
+
+	"example.com/project/pkg/apperrors"
+)
+
+func (u *usecase) Sync(id string) error {
+	err := u.Update(id)
+	if err == apperrors.ErrRecordNotFound {
+		return nil
+	}
+	_, err = u.Get(id)
+	if err == apperrors.ErrRecordNotFound {
+		return nil
+	}
+	err = u.Delete(id)
+	switch err {
+	case apperrors.ErrRecordNotFound:
+		return nil
+	}
+	return err
+}
+
+func (u *usecase) Delete(id string) error {
+	return fmt.Errorf("delete %s: %w", id, apperrors.ErrRecordNotFound)
+}
+`
+
+func TestWrappedComparison(t *testing.T) {
+	auditor := NewAuditor()
+	fset := token.NewFileSet()
+	for _, file := range []struct{ pkgPath, name, src string }{
+		{"example.com/project/pkg/apperrors", "errors.go", apperrorsSrc},
+		{"example.com/project", "handler.go", handlerSrc},
+		{"example.com/project", "update.go", updateSrc},
+		{"example.com/project", "sync.go", syncSrc},
+	} {
+		f, err := parser.ParseFile(fset, file.name, file.src, 0)
+		require.NoError(t, err)
+		require.NoError(t, auditor.Run(file.pkgPath, f, fset))
+	}
+	result := auditor.Result()
+
+	var messages []string
+	for _, finding := range result.Findings {
+		if finding.Rule != WrappedCompareRule {
+			continue
+		}
+		messages = append(messages, fmt.Sprintf("%d %s", finding.Pos.Line, finding.Message))
+	}
+	require.Equal(t, []string{
+		"11 == compares with apperrors.ErrRecordNotFound which project.(*usecase).Update returns wrapped, use errors.Is",
+		"20 switch compares with apperrors.ErrRecordNotFound which project.(*usecase).Delete returns wrapped, use errors.Is",
+	}, messages)
+}
+
+func TestWrappedErrorCauses(t *testing.T) {
+	auditor := NewAuditor()
+	fset := token.NewFileSet()
+	for _, file := range []struct{ pkgPath, name, src string }{
+		{"example.com/project/pkg/apperrors", "errors.go", `package apperrors
+
+import "errors"
+
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrNotFoundTemp = errors.New("not found yet")
+)
+`},
+		{"example.com/project", "find.go", `package project
+
+import (
+	"errors"
+	"fmt"
+
+	"example.com/project/pkg/apperrors"
+)
+
+func Find(id string) error {
+	return fmt.Errorf("find %s: %w", id, apperrors.ErrNotFoundTemp)
+}
+
+func Sync(id string) error {
+	err := Find(id)
+	if errors.Is(err, apperrors.ErrNotFound) || errors.Is(err, apperrors.ErrNotFoundTemp) {
+		return nil
+	}
+	return err
+}
+`},
+	} {
+		f, err := parser.ParseFile(fset, file.name, file.src, 0)
+		require.NoError(t, err)
+		require.NoError(t, auditor.Run(file.pkgPath, f, fset))
+	}
+	result := auditor.Result()
+
+	handlings := result.ErrorHandling()
+	require.Len(t, handlings, 1)
+	require.Len(t, handlings[0].Checked, 1)
+	require.Equal(t, "example.com/project/pkg/apperrors.ErrNotFoundTemp", handlings[0].Checked[0].Check.Target)
+	// ErrNotFound is a prefix of the wrapped ErrNotFoundTemp but is not wrapped.
+	require.Len(t, handlings[0].Dead, 1)
+	require.Equal(t, "example.com/project/pkg/apperrors.ErrNotFound", handlings[0].Dead[0].Target)
+}
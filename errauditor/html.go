@@ -0,0 +1,271 @@
+package errauditor
+
+import (
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+)
+
+// htmlNode is a node of the package tree rendered in the HTML report.
+type htmlNode struct {
+	Name     string
+	Package  *PackageReport
+	Children []*htmlNode
+}
+
+type htmlReport struct {
+	Result      *Result
+	Tree        []*htmlNode
+	Packages    []*PackageReport
+	Definitions []htmlDefinition
+	Statuses    []*StatusReport
+}
+
+type htmlDefinition struct {
+	Anchor string
+	Name   string
+	Pos    string
+}
+
+// WriteHTML writes the result as a self-contained HTML report. The report
+// does not reference any external assets so it can be stored as a build
+// artifact and opened directly in a browser.
+func WriteHTML(w io.Writer, r *Result) error {
+	pkgs := r.Packages()
+	report := htmlReport{
+		Result:   r,
+		Tree:     packageTree(pkgs),
+		Packages: pkgs,
+		Statuses: r.HTTPStatuses(),
+	}
+	// only list the definitions that are referenced by a reported error.
+	seen := make(map[string]bool)
+	for _, pkg := range pkgs {
+		for _, agError := range pkg.Funcs {
+			for _, e := range agError.traced() {
+				if e.Entry == nil || seen[e.Entry.Target] {
+					continue
+				}
+				posn, ok := r.Definition(e.Entry.Target)
+				if !ok {
+					continue
+				}
+				seen[e.Entry.Target] = true
+				report.Definitions = append(report.Definitions, htmlDefinition{
+					Anchor: definitionAnchor(e.Entry.Target),
+					Name:   shortName(e.Entry.Target),
+					Pos:    posn.String(),
+				})
+			}
+		}
+	}
+	sort.Slice(report.Definitions, func(i, j int) bool {
+		return report.Definitions[i].Anchor < report.Definitions[j].Anchor
+	})
+	return htmlTemplate.Execute(w, report)
+}
+
+// packageTree arranges packages by their import path segments, with the
+// prefix shared by all packages collapsed into the root nodes.
+func packageTree(pkgs []*PackageReport) []*htmlNode {
+	prefix := commonPathPrefix(pkgs)
+	root := &htmlNode{}
+	for _, pkg := range pkgs {
+		node := root
+		var segments []string
+		if prefix != "" {
+			segments = append(segments, prefix)
+		}
+		if rel := strings.TrimPrefix(strings.TrimPrefix(pkg.Package, prefix), "/"); rel != "" {
+			if prefix == "" {
+				segments = append(segments, rel)
+			} else {
+				segments = append(segments, strings.Split(rel, "/")...)
+			}
+		}
+		for _, segment := range segments {
+			var child *htmlNode
+			for _, c := range node.Children {
+				if c.Name == segment {
+					child = c
+					break
+				}
+			}
+			if child == nil {
+				child = &htmlNode{Name: segment}
+				node.Children = append(node.Children, child)
+			}
+			node = child
+		}
+		node.Package = pkg
+	}
+	return root.Children
+}
+
+func commonPathPrefix(pkgs []*PackageReport) string {
+	if len(pkgs) < 2 {
+		return ""
+	}
+	prefix := strings.Split(pkgs[0].Package, "/")
+	for _, pkg := range pkgs[1:] {
+		segments := strings.Split(pkg.Package, "/")
+		n := 0
+		for n < len(prefix) && n < len(segments) && prefix[n] == segments[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return strings.Join(prefix, "/")
+}
+
+// htmlAnchor turns a package path into a fragment identifier that survives
+// URL escaping unchanged.
+func htmlAnchor(pkg string) string {
+	return "pkg-" + anchorName(pkg)
+}
+
+// definitionAnchor returns the fragment identifier of the definition of a
+// qualified name.
+func definitionAnchor(target string) string {
+	return "def-" + anchorName(target)
+}
+
+func anchorName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '-'
+	}, name)
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"anchor": htmlAnchor,
+	"status": statusName,
+	"short":  shortName,
+	"trace":  traceString,
+	"traced": func(a *AggregatedError) []*TracedError {
+		return a.traced()
+	},
+	"definition": func(r *Result, e *TracedError) string {
+		if e.Entry == nil {
+			return ""
+		}
+		if _, ok := r.Definition(e.Entry.Target); !ok {
+			return ""
+		}
+		return definitionAnchor(e.Entry.Target)
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>errauditor report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #24292f; }
+code, .pos { font-family: ui-monospace, Menlo, Consolas, monospace; font-size: 0.9em; }
+.pos { color: #57606a; }
+table { border-collapse: collapse; margin: 0.5em 0 1.5em; }
+th, td { border: 1px solid #d0d7de; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background: #f6f8fa; }
+ul.tree, ul.tree ul { list-style: none; padding-left: 1.2em; }
+ul.errors { margin: 0.2em 0; }
+.error { color: #cf222e; }
+.finding { color: #9a6700; }
+section.package { border-top: 1px solid #d0d7de; margin-top: 1.5em; }
+</style>
+</head>
+<body>
+<h1>errauditor report</h1>
+<table>
+<tr><th>Packages</th><td>{{len .Packages}}</td></tr>
+<tr><th>Functions returning errors</th><td>{{len .Result.AggregatedErrors}}</td></tr>
+<tr><th>Wrapped errors</th><td>{{.Result.WrappedErrorCount}}</td></tr>
+<tr><th>Const errors</th><td>{{.Result.ConstErrorCount}}</td></tr>
+<tr><th>Findings</th><td>{{len .Result.Findings}}</td></tr>
+</table>
+
+{{range .Result.EntryPoints}}
+<h2>Entry point <code>{{short .Decl.Key}}</code></h2>
+<p class="pos">{{.Decl.Pos}}</p>
+<table>
+<tr><th>Error</th><th>Trace</th></tr>
+{{range .Errors}}<tr><td class="error"><code>{{.Error}}</code></td><td><code>{{trace .Trace}}</code></td></tr>
+{{end}}
+</table>
+{{end}}
+
+<h2>Packages</h2>
+{{define "node"}}<li>{{if .Package}}<a href="#{{anchor .Package.Package}}">{{.Name}}</a> <span class="pos">({{len .Package.Funcs}} functions)</span>{{else}}{{.Name}}{{end}}
+{{- if .Children}}<ul>{{range .Children}}{{template "node" .}}{{end}}</ul>{{end}}</li>
+{{end}}
+<ul class="tree">{{range .Tree}}{{template "node" .}}{{end}}</ul>
+
+{{$result := .Result}}
+{{range .Packages}}
+<section class="package" id="{{anchor .Package}}">
+<h2><code>{{.Package}}</code></h2>
+<p>Wrapped errors: {{.WrappedErrorCount}} &middot; Const errors: {{.ConstErrorCount}}</p>
+{{if .Funcs}}
+<table>
+<tr><th>Function</th><th>Source</th><th>Errors</th></tr>
+{{range .Funcs}}
+<tr>
+<td><code>{{.Name}}</code>{{if .Generated}} <span class="pos">(generated)</span>{{end}}{{if .Test}} <span class="pos">(test)</span>{{end}}</td>
+<td class="pos">{{.Pos}}</td>
+<td><ul class="errors">{{range traced .}}<li class="error"><code>{{with definition $result .}}<a href="#{{.}}">{{end}}{{.Error}}{{with definition $result .}}</a>{{end}}</code>{{if .Trace}}<br><span class="pos">{{trace .Trace}}</span>{{end}}</li>{{end}}</ul></td>
+</tr>
+{{end}}
+</table>
+{{end}}
+{{if .Findings}}
+<h3>Findings</h3>
+<table>
+<tr><th>Rule</th><th>Function</th><th>Source</th><th>Message</th></tr>
+{{range .Findings}}<tr class="finding"><td>{{.Rule}}</td><td><code>{{.Func}}</code></td><td class="pos">{{.Pos}}</td><td>{{.Message}}</td></tr>
+{{end}}
+</table>
+{{end}}
+</section>
+{{end}}
+
+{{with .Result.Handlings}}
+<h2>Error handling</h2>
+<table>
+<tr><th>Caller</th><th>Callee</th><th>Source</th><th>Checked</th><th>Generic</th></tr>
+{{range .}}<tr><td><code>{{short .Caller}}</code></td><td><code>{{short .Callee}}</code></td><td class="pos">{{.Pos}}</td><td>{{range .Checked}}<code>{{.Error}}</code> ({{.Check.Kind}})<br>{{end}}</td><td>{{range .Generic}}<code>{{.}}</code><br>{{end}}</td></tr>
+{{end}}
+</table>
+{{end}}
+
+{{with .Result.ErrorTypes}}
+<h2>Error types</h2>
+<table>
+<tr><th>Type</th><th>Source</th><th>Methods</th><th>Returned by</th></tr>
+{{range .}}<tr><td><code>{{short .Type}}</code></td><td class="pos">{{.Pos}}</td><td>{{$t := .}}{{range .Methods}}<code>{{.}}</code>{{if ne .Pointer $t.Pointer}} <span class="finding">(receiver mismatch)</span>{{end}}<br>{{end}}</td><td>{{range .Returners}}<code>{{short .}}</code><br>{{end}}</td></tr>
+{{end}}
+</table>
+{{end}}
+
+{{range .Statuses}}
+<h2>HTTP statuses of <code>{{.Mapping.Name}}</code></h2>
+<p class="pos">{{.Mapping.Pos}}</p>
+<table>
+<tr><th>Status</th><th>Codes</th><th>Endpoints</th></tr>
+{{range .Statuses}}<tr><td><code>{{status .}}</code></td><td>{{range $i, $code := .Codes}}{{if $i}}, {{end}}<code>{{$code}}</code>{{end}}</td><td>{{range .Endpoints}}<code>{{.}}</code><br>{{end}}</td></tr>
+{{end}}
+</table>
+{{end}}
+
+{{if .Definitions}}
+<h2>Definitions</h2>
+<table>
+<tr><th>Name</th><th>Source</th></tr>
+{{range .Definitions}}<tr id="{{.Anchor}}"><td><code>{{.Name}}</code></td><td class="pos">{{.Pos}}</td></tr>
+{{end}}
+</table>
+{{end}}
+</body>
+</html>
+`))
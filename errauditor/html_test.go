@@ -0,0 +1,92 @@
+package errauditor
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteHTML(t *testing.T) {
+	result := runSource(t, "example.com/project", usecaseSrc+`
+var ErrRecordNotFound = errors.New("record not found")
+`)
+	result.Findings = append(result.Findings, &Finding{
+		Rule:    "example",
+		Package: "example.com/project",
+		Func:    "GetAddressByUser",
+		Message: "lookup may fail",
+	})
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteHTML(&buf, result))
+
+	html := buf.String()
+	require.NotContains(t, html, "<script src=")
+	require.NotContains(t, html, "<link ")
+	require.Contains(t, html, `id="pkg-example-com-project"`)
+	require.Contains(t, html, "GetAddressByUser")
+	// the returned apperrors.ErrRecordNotFound is not audited, it does not
+	// link to the project variable of the same name.
+	require.NotContains(t, html, `href="#def-`)
+	require.NotContains(t, html, `<tr id="def-`)
+	require.Contains(t, html, "lookup may fail")
+}
+
+func TestWriteHTMLDefinitions(t *testing.T) {
+	auditor := NewAuditor()
+	fset := token.NewFileSet()
+	for _, file := range []struct{ pkgPath, name, src string }{
+		{"example.com/project/users", "users.go", `package users
+
+import "errors"
+
+var ErrNotFound = errors.New("user not found")
+`},
+		{"example.com/project/orders", "orders.go", `package orders
+
+import "errors"
+
+var ErrNotFound = errors.New("order not found")
+`},
+		{"example.com/project", "usecase.go", `package project
+
+import (
+	"example.com/project/orders"
+	"example.com/project/users"
+)
+
+func GetUser(id string) error {
+	return users.ErrNotFound
+}
+
+func GetOrder(id string) error {
+	return orders.ErrNotFound
+}
+
+func Handle(id string) error {
+	if id == "" {
+		return orders.ErrNotFound
+	}
+	return GetUser(id)
+}
+`},
+	} {
+		f, err := parser.ParseFile(fset, file.name, file.src, 0)
+		require.NoError(t, err)
+		require.NoError(t, auditor.Run(file.pkgPath, f, fset))
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteHTML(&buf, auditor.Result()))
+	html := buf.String()
+	// each error links to the ErrNotFound of its own package, including the
+	// error Handle returns through its call.
+	require.Contains(t, html, `<tr id="def-example-com-project-orders-ErrNotFound"><td><code>orders.ErrNotFound</code></td><td class="pos">orders.go:5:5</td></tr>`)
+	require.Contains(t, html, `<tr id="def-example-com-project-users-ErrNotFound"><td><code>users.ErrNotFound</code></td><td class="pos">users.go:5:5</td></tr>`)
+	require.Equal(t, 2, strings.Count(html, `<a href="#def-example-com-project-users-ErrNotFound">`))
+	require.Equal(t, 2, strings.Count(html, `<a href="#def-example-com-project-orders-ErrNotFound">`))
+}
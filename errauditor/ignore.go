@@ -0,0 +1,277 @@
+package errauditor
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// UnusedIgnoreRule is reported for ignore directives that suppress no finding.
+const UnusedIgnoreRule = "unused-ignore"
+
+// UnknownRuleRule is reported for ignore directives listing a rule that does
+// not exist, such as a misspelled one.
+const UnknownRuleRule = "unknown-ignore-rule"
+
+const ignoreDirective = "//errauditor:ignore"
+
+// rules holds the names of the rules an ignore directive may list.
+var rules = map[string]bool{
+	UnusedIgnoreRule:   true,
+	UnknownRuleRule:    true,
+	HTTPStatusRule:     true,
+	DeadCheckRule:      true,
+	WrappedCompareRule: true,
+	ErrorReceiverRule:  true,
+}
+
+// Ignore is an `//errauditor:ignore [rule,...] reason` directive. It suppresses
+// the findings of the listed rules, or of every rule when none is listed, on
+// the lines it covers:
+//
+//   - the whole file when it precedes the package clause,
+//   - the function when it is part of the function doc comment,
+//   - the statement it trails or that follows it otherwise.
+type Ignore struct {
+	Package string
+	// Func is the function enclosing the directive, if any.
+	Func string
+	Pos  token.Position
+	// Start and End are the first and last line covered in Pos.Filename.
+	Start, End int
+	Rules      []string
+	Reason     string
+	// Used is set once the directive suppressed a finding.
+	Used bool
+}
+
+// suppresses reports whether the directive covers the finding.
+func (ig *Ignore) suppresses(finding *Finding) bool {
+	if ig.Pos.Filename != finding.Pos.Filename || finding.Pos.Line < ig.Start || finding.Pos.Line > ig.End {
+		return false
+	}
+	if len(ig.Rules) == 0 {
+		return true
+	}
+	for _, rule := range ig.Rules {
+		if rule == finding.Rule {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIgnoreDirective splits the text of a comment into the listed rules and
+// the reason. The first word is only taken as the rule list when it names known
+// rules, is shaped like a rule name or is a comma-separated list, so that a
+// bare reason suppresses all rules and a misspelled rule suppresses none.
+func parseIgnoreDirective(text string) (ruleList []string, reason string, ok bool) {
+	rest := strings.TrimPrefix(text, ignoreDirective)
+	if len(rest) == len(text) || (rest != "" && rest[0] != ' ' && rest[0] != '\t') {
+		return nil, "", false
+	}
+	fields := strings.Fields(rest)
+	if len(fields) > 0 && isRuleList(fields[0]) {
+		ruleList = strings.Split(fields[0], ",")
+		fields = fields[1:]
+	}
+	return ruleList, strings.Join(fields, " "), true
+}
+
+func isRuleList(field string) bool {
+	names := strings.Split(field, ",")
+	known := true
+	for _, name := range names {
+		if name == "" || strings.TrimLeft(name, "abcdefghijklmnopqrstuvwxyz0123456789-") != "" {
+			return false
+		}
+		known = known && (rules[name] || isRuleName(name))
+	}
+	return known || len(names) > 1
+}
+
+// isRuleName reports whether a word is shaped like a rule name, lowercase
+// words joined by hyphens such as `dead-error-check`.
+func isRuleName(name string) bool {
+	return strings.Contains(name, "-") && strings.Trim(name, "-") == name && !strings.Contains(name, "--") &&
+		strings.TrimLeft(name, "abcdefghijklmnopqrstuvwxyz0123456789-") == ""
+}
+
+// checkIgnores reports the rules listed by ignore directives that are shaped
+// like rule names but do not exist.
+func (r *Result) checkIgnores() {
+	for _, ig := range r.Ignores {
+		for _, name := range ig.Rules {
+			if rules[name] || !isRuleName(name) {
+				continue
+			}
+			r.Findings = append(r.Findings, &Finding{
+				Rule:    UnknownRuleRule,
+				Package: ig.Package,
+				Func:    ig.Func,
+				Pos:     ig.Pos,
+				Message: fmt.Sprintf("ignore directive lists unknown rule %s", name),
+			})
+		}
+	}
+}
+
+// span is the line range of a statement or declaration.
+type span struct {
+	start, end  int
+	pos, endPos token.Pos
+}
+
+// parseIgnores returns the ignore directives of file with the lines they cover.
+func parseIgnores(pkgPath string, file *ast.File, fset *token.FileSet) []*Ignore {
+	var ignores []*Ignore
+	var spans []span
+	for _, group := range file.Comments {
+		for _, c := range group.List {
+			ruleList, reason, ok := parseIgnoreDirective(c.Text)
+			if !ok {
+				continue
+			}
+			ig := &Ignore{
+				Package: pkgPath,
+				Pos:     fset.Position(c.Pos()),
+				Rules:   ruleList,
+				Reason:  reason,
+			}
+			if c.Pos() < file.Package {
+				ig.Start, ig.End = 1, fset.File(file.Pos()).LineCount()
+			} else {
+				if spans == nil {
+					spans = fileSpans(file, fset)
+				}
+				ig.Start, ig.End = ignoreScope(spans, ig.Pos.Line, c.Pos())
+				ig.Func = enclosingFunc(file, fset, ig.End)
+			}
+			ignores = append(ignores, ig)
+		}
+	}
+	return ignores
+}
+
+func fileSpans(file *ast.File, fset *token.FileSet) []span {
+	spans := []span{}
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch node.(type) {
+		case ast.Stmt, ast.Decl:
+			spans = append(spans, span{
+				start:  fset.Position(node.Pos()).Line,
+				end:    fset.Position(node.End()).Line,
+				pos:    node.Pos(),
+				endPos: node.End(),
+			})
+		}
+		return true
+	})
+	return spans
+}
+
+// ignoreScope returns the lines covered by a directive on line at pos: the
+// statements starting before it on the same line, the line itself when it
+// trails the end of a statement, or else the statements starting on the next
+// line with code.
+func ignoreScope(spans []span, line int, pos token.Pos) (int, int) {
+	end, trailing := line, false
+	for _, s := range spans {
+		if s.start == line && s.pos < pos {
+			trailing = true
+			if s.end > end {
+				end = s.end
+			}
+		} else if s.end == line && s.endPos <= pos {
+			trailing = true
+		}
+	}
+	if trailing {
+		return line, end
+	}
+
+	next := 0
+	for _, s := range spans {
+		if s.start > line && (next == 0 || s.start < next) {
+			next = s.start
+		}
+	}
+	for _, s := range spans {
+		if s.start == next && s.end > end {
+			end = s.end
+		}
+	}
+	return line, end
+}
+
+// enclosingFunc returns the name of the function declared around line.
+func enclosingFunc(file *ast.File, fset *token.FileSet, line int) string {
+	for _, d := range file.Decls {
+		decl, ok := d.(*ast.FuncDecl)
+		if ok && fset.Position(decl.Pos()).Line <= line && line <= fset.Position(decl.End()).Line {
+			return decl.Name.Name
+		}
+	}
+	return ""
+}
+
+// applyIgnores drops the findings suppressed by an ignore directive and marks
+// the directives that suppressed them as used.
+func (r *Result) applyIgnores() {
+	if len(r.Ignores) == 0 {
+		return
+	}
+	findings := r.Findings[:0]
+	for _, finding := range r.Findings {
+		if !r.suppress(finding, nil) {
+			findings = append(findings, finding)
+		}
+	}
+	r.Findings = findings
+}
+
+// suppress reports whether a directive other than self covers the finding and
+// marks every such directive as used.
+func (r *Result) suppress(finding *Finding, self *Ignore) bool {
+	suppressed := false
+	for _, ig := range r.Ignores {
+		if ig != self && ig.suppresses(finding) {
+			ig.Used = true
+			suppressed = true
+		}
+	}
+	return suppressed
+}
+
+// ReportUnusedIgnores adds an unused-ignore finding for every directive that
+// suppressed no finding. These findings may be suppressed themselves by a
+// directive listing the unused-ignore rule.
+func (r *Result) ReportUnusedIgnores() {
+	var unused []*Ignore
+	for _, ig := range r.Ignores {
+		if !ig.Used {
+			unused = append(unused, ig)
+		}
+	}
+	findings := make([]*Finding, len(unused))
+	for i, ig := range unused {
+		findings[i] = &Finding{
+			Rule:    UnusedIgnoreRule,
+			Package: ig.Package,
+			Func:    ig.Func,
+			Pos:     ig.Pos,
+			Message: "ignore directive suppresses no finding",
+		}
+		if r.suppress(findings[i], ig) {
+			findings[i] = nil
+		}
+	}
+	for i, ig := range unused {
+		// the directive may have been used to suppress another unused one.
+		if findings[i] != nil && !ig.Used {
+			r.Findings = append(r.Findings, findings[i])
+		}
+	}
+	r.Sort()
+}
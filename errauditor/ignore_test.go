@@ -0,0 +1,127 @@
+package errauditor
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const ignoreSrc = `package project
+
+import "errors"
+
+//errauditor:ignore legacy code, see #12
+func Legacy() error {
+	return errors.New("legacy")
+}
+
+func Statement() error {
+	//errauditor:ignore unused-ignore,example checked by the caller
+	if err := do(); err != nil {
+		return err
+	}
+	return nil //errauditor:ignore example
+}
+
+func do() error { return nil }
+`
+
+func TestParseIgnores(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "usecase.go", ignoreSrc, parser.ParseComments)
+	require.NoError(t, err)
+
+	ignores := parseIgnores("example.com/project", f, fset)
+	require.Len(t, ignores, 3)
+
+	require.Nil(t, ignores[0].Rules)
+	require.Equal(t, "legacy code, see #12", ignores[0].Reason)
+	require.Equal(t, "Legacy", ignores[0].Func)
+	require.Equal(t, 5, ignores[0].Start)
+	require.Equal(t, 8, ignores[0].End)
+
+	require.Equal(t, []string{"unused-ignore", "example"}, ignores[1].Rules)
+	require.Equal(t, "checked by the caller", ignores[1].Reason)
+	require.Equal(t, "Statement", ignores[1].Func)
+	require.Equal(t, 11, ignores[1].Start)
+	require.Equal(t, 14, ignores[1].End)
+
+	require.Nil(t, ignores[2].Rules)
+	require.Equal(t, "example", ignores[2].Reason)
+	require.Equal(t, 15, ignores[2].Start)
+	require.Equal(t, 15, ignores[2].End)
+}
+
+func TestParseIgnoresFile(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "usecase.go", "//errauditor:ignore vendored\n\n"+ignoreSrc+"//errauditor:ignored\n", parser.ParseComments)
+	require.NoError(t, err)
+
+	ignores := parseIgnores("example.com/project", f, fset)
+	require.Len(t, ignores, 4)
+	require.Equal(t, 1, ignores[0].Start)
+	require.Equal(t, 21, ignores[0].End)
+	require.Empty(t, ignores[0].Func)
+}
+
+func TestAuditorIgnores(t *testing.T) {
+	auditor := NewAuditor()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "usecase.go", ignoreSrc, parser.ParseComments)
+	require.NoError(t, err)
+	require.NoError(t, auditor.Run("example.com/project", f, fset))
+	auditor.Merge(&Result{Findings: []*Finding{
+		{Rule: "example", Pos: token.Position{Filename: "usecase.go", Line: 7}},
+		{Rule: "example", Pos: token.Position{Filename: "usecase.go", Line: 13}},
+		{Rule: "other", Pos: token.Position{Filename: "usecase.go", Line: 13}},
+		{Rule: "other", Pos: token.Position{Filename: "usecase.go", Line: 18}},
+	}})
+
+	result := auditor.Result()
+	require.Len(t, result.Findings, 2)
+	require.Equal(t, 13, result.Findings[0].Pos.Line)
+	require.Equal(t, "other", result.Findings[0].Rule)
+	require.Equal(t, 18, result.Findings[1].Pos.Line)
+
+	result.ReportUnusedIgnores()
+	require.Len(t, result.Findings, 3)
+	require.Equal(t, UnusedIgnoreRule, result.Findings[2].Rule)
+	require.Equal(t, 15, result.Findings[2].Pos.Line)
+	require.Equal(t, "Statement", result.Findings[2].Func)
+
+	// the auditor keeps its directives unused for the next result.
+	require.False(t, auditor.Result().Ignores[2].Used)
+}
+
+func TestReportUnusedIgnoresSuppressed(t *testing.T) {
+	result := runSource(t, "example.com/project", "//errauditor:ignore unused-ignore generated stubs\n\n"+ignoreSrc)
+	result.ReportUnusedIgnores()
+	require.Empty(t, result.Findings)
+	require.True(t, result.Ignores[0].Used)
+	require.False(t, result.Ignores[1].Used)
+
+	result = runSource(t, "example.com/project", "//errauditor:ignore unused-ignore nothing else\n\npackage project\n")
+	result.ReportUnusedIgnores()
+	require.Len(t, result.Findings, 1)
+	require.Equal(t, 1, result.Findings[0].Pos.Line)
+}
+
+func TestIgnoreUnknownRule(t *testing.T) {
+	result := runSource(t, "example.com/project", `package project
+
+func Statement() error {
+	return nil //errauditor:ignore dead-eror-check legacy
+}
+`)
+	require.Equal(t, []string{"dead-eror-check"}, result.Ignores[0].Rules)
+	require.Equal(t, "legacy", result.Ignores[0].Reason)
+
+	// the misspelled rule is reported rather than suppressing every rule.
+	require.Len(t, result.Findings, 1)
+	require.Equal(t, UnknownRuleRule, result.Findings[0].Rule)
+	require.Equal(t, 4, result.Findings[0].Pos.Line)
+	require.Equal(t, "Statement", result.Findings[0].Func)
+	require.Equal(t, "ignore directive lists unknown rule dead-eror-check", result.Findings[0].Message)
+}
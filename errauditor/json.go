@@ -0,0 +1,211 @@
+package errauditor
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// JSONReport is the result as written by WriteJSON.
+type JSONReport struct {
+	EntryPoints []*JSONEntryPoint `json:"entry-points,omitempty"`
+	Packages    []*JSONPackage    `json:"packages"`
+	Handlings   []*JSONHandling   `json:"handlings,omitempty"`
+	ErrorTypes  []*JSONErrorType  `json:"error-types,omitempty"`
+}
+
+// JSONEntryPoint is an entry point the result is scoped to.
+type JSONEntryPoint struct {
+	Func   string       `json:"func"`
+	Pos    string       `json:"pos"`
+	Errors []*JSONError `json:"errors"`
+}
+
+// JSONPackage holds the functions and findings of a package.
+type JSONPackage struct {
+	Package  string         `json:"package"`
+	Funcs    []*JSONFunc    `json:"funcs,omitempty"`
+	Findings []*JSONFinding `json:"findings,omitempty"`
+}
+
+// JSONFunc is a function with the errors it can return.
+type JSONFunc struct {
+	Func      string       `json:"func"`
+	Pos       string       `json:"pos"`
+	Test      bool         `json:"test,omitempty"`
+	Generated bool         `json:"generated,omitempty"`
+	Errors    []*JSONError `json:"errors"`
+}
+
+// JSONError is an error with its trace and the tree of the errors it wraps.
+type JSONError struct {
+	Error  string    `json:"error"`
+	Kind   ErrorKind `json:"kind,omitempty"`
+	Expr   string    `json:"expr,omitempty"`
+	Target string    `json:"target,omitempty"`
+	// Wrapped is set when the error is wrapped on its trace.
+	Wrapped bool         `json:"wrapped,omitempty"`
+	Trace   []string     `json:"trace,omitempty"`
+	Causes  []*JSONError `json:"causes,omitempty"`
+}
+
+// JSONFinding is a rule violation.
+type JSONFinding struct {
+	Rule    string `json:"rule"`
+	Func    string `json:"func"`
+	Pos     string `json:"pos"`
+	Message string `json:"message"`
+}
+
+// JSONHandling is a call whose error is checked or not returned, with the
+// errors of the callee it checks and the ones it handles generically.
+type JSONHandling struct {
+	Caller  string              `json:"caller"`
+	Callee  string              `json:"callee"`
+	Pos     string              `json:"pos"`
+	Checked []*JSONCheckedError `json:"checked,omitempty"`
+	Generic []string            `json:"generic,omitempty"`
+	// Dead holds the checks against errors the callee never returns.
+	Dead []*JSONCheck `json:"dead,omitempty"`
+	// Wrapped holds the == and switch checks against errors the callee
+	// returns wrapped.
+	Wrapped []*JSONCheck `json:"wrapped,omitempty"`
+}
+
+// JSONCheckedError is an error of a callee matched by a check.
+type JSONCheckedError struct {
+	Error string     `json:"error"`
+	Check *JSONCheck `json:"check"`
+}
+
+// JSONCheck is a check of the error returned by a call.
+type JSONCheck struct {
+	Kind   CheckKind `json:"kind"`
+	Target string    `json:"target"`
+	Pos    string    `json:"pos"`
+}
+
+// JSONErrorType is a type implementing error.
+type JSONErrorType struct {
+	Type       string             `json:"type"`
+	Pos        string             `json:"pos"`
+	Pointer    bool               `json:"pointer,omitempty"`
+	Methods    []*JSONErrorMethod `json:"methods"`
+	ReturnedBy []string           `json:"returned-by,omitempty"`
+}
+
+// JSONErrorMethod is a method of an error type.
+type JSONErrorMethod struct {
+	Name    string `json:"name"`
+	Result  string `json:"result,omitempty"`
+	Pointer bool   `json:"pointer,omitempty"`
+	Pos     string `json:"pos"`
+	// Mismatch is set when the receiver differs from the receiver of Error.
+	Mismatch bool `json:"mismatch,omitempty"`
+}
+
+// JSON returns the report written by WriteJSON.
+func (r *Result) JSON() *JSONReport {
+	report := &JSONReport{Packages: []*JSONPackage{}}
+	for _, entry := range r.EntryPoints {
+		report.EntryPoints = append(report.EntryPoints, &JSONEntryPoint{
+			Func:   shortName(entry.Decl.Key()),
+			Pos:    entry.Decl.Pos.String(),
+			Errors: jsonErrors(entry.Errors),
+		})
+	}
+	for _, pkg := range r.Packages() {
+		p := &JSONPackage{Package: pkg.Package}
+		for _, agError := range pkg.Funcs {
+			p.Funcs = append(p.Funcs, &JSONFunc{
+				Func:      agError.Name(),
+				Pos:       agError.Pos.String(),
+				Test:      agError.Test,
+				Generated: agError.Generated,
+				Errors:    jsonErrors(agError.traced()),
+			})
+		}
+		for _, finding := range pkg.Findings {
+			p.Findings = append(p.Findings, &JSONFinding{
+				Rule:    finding.Rule,
+				Func:    finding.Func,
+				Pos:     finding.Pos.String(),
+				Message: finding.Message,
+			})
+		}
+		report.Packages = append(report.Packages, p)
+	}
+	for _, h := range r.Handlings {
+		j := &JSONHandling{
+			Caller:  shortName(h.Caller),
+			Callee:  shortName(h.Callee),
+			Pos:     h.Pos.String(),
+			Generic: h.Generic,
+			Dead:    jsonChecks(h.Dead),
+			Wrapped: jsonChecks(h.Wrapped),
+		}
+		for _, e := range h.Checked {
+			j.Checked = append(j.Checked, &JSONCheckedError{Error: e.Error, Check: jsonCheck(e.Check)})
+		}
+		report.Handlings = append(report.Handlings, j)
+	}
+	for _, t := range r.ErrorTypes {
+		j := &JSONErrorType{Type: shortName(t.Type), Pos: t.Pos.String(), Pointer: t.Pointer, Methods: []*JSONErrorMethod{}}
+		for _, m := range t.Methods {
+			j.Methods = append(j.Methods, &JSONErrorMethod{
+				Name:     m.Name,
+				Result:   m.Result,
+				Pointer:  m.Pointer,
+				Pos:      m.Pos.String(),
+				Mismatch: m.Pointer != t.Pointer,
+			})
+		}
+		for _, fn := range t.Returners {
+			j.ReturnedBy = append(j.ReturnedBy, shortName(fn))
+		}
+		report.ErrorTypes = append(report.ErrorTypes, j)
+	}
+	return report
+}
+
+func jsonErrors(traced []*TracedError) []*JSONError {
+	errors := make([]*JSONError, len(traced))
+	for i, e := range traced {
+		errors[i] = &JSONError{Error: e.Error, Wrapped: e.Wrapped}
+		if e.Entry != nil {
+			errors[i] = jsonEntry(e.Entry)
+			errors[i].Wrapped = e.Wrapped
+		}
+		for _, hop := range e.Trace {
+			errors[i].Trace = append(errors[i].Trace, hop.String())
+		}
+	}
+	return errors
+}
+
+// jsonEntry returns an error entry with the tree of the errors it wraps.
+func jsonEntry(e *ErrorEntry) *JSONError {
+	j := &JSONError{Error: e.String(), Kind: e.Kind, Expr: e.Expr, Target: e.Target}
+	for _, cause := range e.Causes {
+		j.Causes = append(j.Causes, jsonEntry(cause))
+	}
+	return j
+}
+
+// WriteJSON writes the result as indented JSON, for other tools to consume.
+func WriteJSON(w io.Writer, r *Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r.JSON())
+}
+
+func jsonChecks(checks []*Check) []*JSONCheck {
+	var j []*JSONCheck
+	for _, c := range checks {
+		j = append(j, jsonCheck(c))
+	}
+	return j
+}
+
+func jsonCheck(c *Check) *JSONCheck {
+	return &JSONCheck{Kind: c.Kind, Target: c.Target, Pos: c.Pos.String()}
+}
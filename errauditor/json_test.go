@@ -0,0 +1,127 @@
+package errauditor
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const joinSrc = `package project
+
+import (
+	"errors"
+	"fmt"
+
+	"example.com/project/pkg/apperrors"
+)
+
+func Close(id string) error {
+	err := flush(id)
+	return errors.Join(err, apperrors.ErrRecordNotFound, fmt.Errorf("close %s: %w: %w", id, apperrors.ErrUnauthorized, release(id)))
+}
+
+func flush(id string) error {
+	return apperrors.ErrInternalServerError("flush")
+}
+
+func release(id string) error {
+	return apperrors.ErrSessionExpired
+}
+`
+
+func TestJoin(t *testing.T) {
+	result := runSource(t, "example.com/project", joinSrc)
+	closeErr := result.AggregatedErrors[0]
+	require.Equal(t, "Close", closeErr.Func)
+	require.Len(t, closeErr.Errors, 1)
+
+	join := closeErr.Errors[0]
+	require.Equal(t, WrappedError, join.Kind)
+	var causes []string
+	for _, cause := range join.Causes {
+		causes = append(causes, string(cause.Kind)+" "+cause.String())
+	}
+	require.Equal(t, []string{"var err", "const ErrRecordNotFound()", `wrapped Errorf("close %s: %w: %w",)`}, causes)
+	require.Len(t, join.Causes[2].Causes, 1)
+	require.Equal(t, "ErrUnauthorized()", join.Causes[2].Causes[0].String())
+	require.Equal(t, []string{"Join()", "ErrRecordNotFound()", `Errorf("close %s: %w: %w",)`, "ErrUnauthorized()"}, closeErr.ErrorStrings())
+
+	// the errors of the joined calls are traced as wrapped.
+	var traced []string
+	for _, e := range closeErr.Traces {
+		require.True(t, e.Wrapped, e.Error)
+		traced = append(traced, e.Error)
+	}
+	require.Equal(t, []string{"Join()", `ErrInternalServerError("flush",)`, "ErrSessionExpired()"}, traced)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteText(&buf, result))
+	require.Contains(t, buf.String(), "---Join() \n   +--err \n   +--ErrRecordNotFound() \n   +--Errorf(\"close %s: %w: %w\",) \n      +--ErrUnauthorized() \n")
+
+	buf.Reset()
+	require.NoError(t, WriteJSON(&buf, result))
+	var report JSONReport
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &report))
+	require.Len(t, report.Packages, 1)
+	errs := report.Packages[0].Funcs[0].Errors
+	require.Equal(t, "Join()", errs[0].Error)
+	require.Equal(t, "errors.Join", errs[0].Target)
+	require.Len(t, errs[0].Causes, 3)
+	require.Equal(t, "ErrUnauthorized()", errs[0].Causes[2].Causes[0].Error)
+	require.Equal(t, []string{"project.Close (usecase.go:11)", "project.flush (usecase.go:16)"}, errs[1].Trace)
+}
+
+func TestFormatVerbs(t *testing.T) {
+	require.Equal(t, "sw", string(formatVerbs("%s: %w")))
+	require.Equal(t, "ww", string(formatVerbs("%w: %w")))
+	require.Equal(t, "*dw", string(formatVerbs("%*d%% %w")))
+	require.Equal(t, "vw", string(formatVerbs("%+v: %-10w")))
+}
+
+func TestJSONHandlings(t *testing.T) {
+	auditor := NewAuditor()
+	fset := token.NewFileSet()
+	for _, file := range []struct{ pkgPath, name, src string }{
+		{"example.com/project/pkg/apperrors", "errors.go", apperrorsSrc},
+		{"example.com/project", "handler.go", handlerSrc},
+		{"example.com/project", "handle.go", handleSrc},
+		{"example.com/project", "update.go", updateSrc},
+		{"example.com/project", "sync.go", syncSrc},
+	} {
+		f, err := parser.ParseFile(fset, file.name, file.src, 0)
+		require.NoError(t, err)
+		require.NoError(t, auditor.Run(file.pkgPath, f, fset))
+	}
+	result := auditor.Result()
+	result.Handlings = result.ErrorHandling()
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteJSON(&buf, result))
+	var report JSONReport
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &report))
+	require.Equal(t, result.JSON().Handlings, report.Handlings)
+
+	var handle, sync *JSONHandling
+	for _, h := range report.Handlings {
+		switch {
+		case h.Caller == "project.(*usecase).Handle" && h.Callee == "project.(*usecase).Get":
+			handle = h
+		case h.Caller == "project.(*usecase).Sync" && h.Callee == "project.(*usecase).Update":
+			sync = h
+		}
+	}
+	require.NotNil(t, handle)
+	require.Equal(t, "handle.go:13:12", handle.Pos)
+	require.Equal(t, &JSONCheckedError{
+		Error: "ErrRecordNotFound()",
+		Check: &JSONCheck{Kind: IsCheck, Target: "example.com/project/pkg/apperrors.ErrRecordNotFound", Pos: "handle.go:14:5"},
+	}, handle.Checked[1])
+	require.Equal(t, []*JSONCheck{{Kind: EqualCheck, Target: "example.com/project.ErrLocal", Pos: "handle.go:21:5"}}, handle.Dead)
+
+	require.NotNil(t, sync)
+	require.Equal(t, []*JSONCheck{{Kind: EqualCheck, Target: "example.com/project/pkg/apperrors.ErrRecordNotFound", Pos: "sync.go:11:5"}}, sync.Wrapped)
+}
@@ -0,0 +1,42 @@
+package errauditor
+
+// builtinConstructors are the constructors of popular error packages. They are
+// recognized without being declared, declared constructors take precedence.
+var builtinConstructors = []Constructor{
+	{Func: "github.com/pkg/errors.New", Message: 0, Code: -1, Cause: -1},
+	{Func: "github.com/pkg/errors.Errorf", Message: 0, Code: -1, Cause: -1},
+	{Func: "github.com/pkg/errors.Wrap", Message: 1, Code: -1, Cause: 0},
+	{Func: "github.com/pkg/errors.Wrapf", Message: 1, Code: -1, Cause: 0},
+	{Func: "github.com/pkg/errors.WithStack", Message: -1, Code: -1, Cause: 0},
+	{Func: "github.com/pkg/errors.WithMessage", Message: 1, Code: -1, Cause: 0},
+	{Func: "github.com/pkg/errors.WithMessagef", Message: 1, Code: -1, Cause: 0},
+
+	{Func: "github.com/cockroachdb/errors.New", Message: 0, Code: -1, Cause: -1},
+	{Func: "github.com/cockroachdb/errors.Newf", Message: 0, Code: -1, Cause: -1},
+	{Func: "github.com/cockroachdb/errors.Errorf", Message: 0, Code: -1, Cause: -1},
+	{Func: "github.com/cockroachdb/errors.Wrap", Message: 1, Code: -1, Cause: 0},
+	{Func: "github.com/cockroachdb/errors.Wrapf", Message: 1, Code: -1, Cause: 0},
+	{Func: "github.com/cockroachdb/errors.WithStack", Message: -1, Code: -1, Cause: 0},
+	{Func: "github.com/cockroachdb/errors.WithMessage", Message: 1, Code: -1, Cause: 0},
+	{Func: "github.com/cockroachdb/errors.WithMessagef", Message: 1, Code: -1, Cause: 0},
+
+	{Func: "google.golang.org/grpc/status.Error", Message: 1, Code: 0, Cause: -1},
+	{Func: "google.golang.org/grpc/status.Errorf", Message: 1, Code: 0, Cause: -1},
+}
+
+// builtins holds the built-in constructors by qualified name.
+var builtins = func() Registry {
+	registry := make(Registry, len(builtinConstructors))
+	for i := range builtinConstructors {
+		registry[builtinConstructors[i].Func] = &builtinConstructors[i]
+	}
+	return registry
+}()
+
+// joinFuncs are the functions combining errors, wrapping all their arguments.
+var joinFuncs = map[string]bool{
+	"errors.Join":                        true,
+	"github.com/cockroachdb/errors.Join": true,
+	"go.uber.org/multierr.Combine":       true,
+	"go.uber.org/multierr.Append":        true,
+}
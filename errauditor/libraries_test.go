@@ -0,0 +1,87 @@
+package errauditor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const librariesSrc = `package project
+
+import (
+	crdb "github.com/cockroachdb/errors"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var ErrClosed = errors.New("closed")
+
+func Save(id string) error {
+	err := store(id)
+	if errors.Is(err, ErrClosed) {
+		return errors.WithStack(err)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "save %s", id)
+	}
+	return multierr.Combine(flush(), crdb.WithMessage(ErrClosed, "flush"))
+}
+
+func Get(id string) error {
+	return status.Errorf(codes.NotFound, "user %s not found", id)
+}
+
+func store(id string) error {
+	return ErrClosed
+}
+
+func flush() error {
+	return crdb.Newf("flush %d", 1)
+}
+`
+
+func TestLibraries(t *testing.T) {
+	result := runSource(t, "example.com/project", librariesSrc)
+	agErrors := result.aggregatedErrors()
+
+	save := agErrors["example.com/project.Save"]
+	require.Equal(t, []string{
+		"WithStack(cause: err)",
+		`Wrapf(message: "save %s", cause: err)`,
+		"Combine()",
+		`WithMessage(message: "flush", cause: ErrClosed)`,
+	}, save.ErrorStrings())
+	for _, e := range save.Errors {
+		require.Equal(t, WrappedError, e.Kind, e.Text)
+	}
+	require.Equal(t, VarError, save.Errors[0].Causes[0].Kind)
+	// flush() is traced on its own.
+	require.Len(t, save.Errors[2].Causes, 1)
+
+	// the errors of the wrapped calls are traced as wrapped.
+	var traced []string
+	for _, e := range save.Traces {
+		if len(e.Trace) > 1 {
+			require.True(t, e.Wrapped, e.Error)
+			traced = append(traced, e.Error)
+		}
+	}
+	require.Equal(t, []string{`Newf(message: "flush %d")`}, traced)
+
+	get := agErrors["example.com/project.Get"]
+	require.Equal(t, []*Construction{{
+		Constructor: "google.golang.org/grpc/status.Errorf",
+		Code:        "codes.NotFound",
+		Message:     `"user %s not found"`,
+	}}, get.Constructions)
+
+	var checks []string
+	for _, call := range result.Decls["example.com/project.Save"].Calls {
+		for _, c := range call.Checks {
+			checks = append(checks, call.Name+" "+string(c.Kind)+" "+c.Target)
+		}
+	}
+	require.Equal(t, []string{"store errors.Is example.com/project.ErrClosed"}, checks)
+}
@@ -0,0 +1,134 @@
+package errauditor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteMarkdown writes the result as GitHub flavored markdown, suitable for
+// pasting into pull request descriptions and comments. Each package gets a
+// summary table followed by a collapsible section per function.
+func WriteMarkdown(w io.Writer, r *Result) error {
+	bw := bufio.NewWriter(w)
+	pkgs := r.Packages()
+
+	fmt.Fprintf(bw, "## errauditor report\n\n")
+	fmt.Fprintf(bw, "| Packages | Functions | Wrapped errors | Const errors | Findings |\n")
+	fmt.Fprintf(bw, "|---:|---:|---:|---:|---:|\n")
+	fmt.Fprintf(bw, "| %d | %d | %d | %d | %d |\n",
+		len(pkgs), len(r.AggregatedErrors), r.WrappedErrorCount, r.ConstErrorCount, len(r.Findings))
+
+	for _, entry := range r.EntryPoints {
+		fmt.Fprintf(bw, "\n### Entry point `%s`\n\n", markdownCell(shortName(entry.Decl.Key())))
+		fmt.Fprintf(bw, "| Error | Trace |\n")
+		fmt.Fprintf(bw, "|---|---|\n")
+		for _, e := range entry.Errors {
+			fmt.Fprintf(bw, "| `%s` | `%s` |\n", markdownCell(strings.ReplaceAll(e.Error, "`", "'")), markdownCell(traceString(e.Trace)))
+		}
+	}
+
+	for _, pkg := range pkgs {
+		fmt.Fprintf(bw, "\n### `%s`\n\n", pkg.Package)
+		if len(pkg.Funcs) > 0 {
+			fmt.Fprintf(bw, "| Function | Source | Errors | Wrapped | Const |\n")
+			fmt.Fprintf(bw, "|---|---|---:|---:|---:|\n")
+			for _, agError := range pkg.Funcs {
+				fmt.Fprintf(bw, "| `%s`%s | %s | %d | %d | %d |\n",
+					markdownCell(agError.Name()), funcNote(agError), markdownCell(agError.Pos.String()),
+					len(agError.Errors), agError.WrappedErrorCount, agError.ConstErrorCount)
+			}
+			for _, agError := range pkg.Funcs {
+				fmt.Fprintf(bw, "\n<details>\n<summary><code>%s</code></summary>\n\n", markdownHTML(agError.Name()))
+				for _, e := range agError.traced() {
+					fmt.Fprintf(bw, "- `%s`", strings.ReplaceAll(e.Error, "`", "'"))
+					if len(e.Trace) > 0 {
+						fmt.Fprintf(bw, " from `%s`", traceString(e.Trace))
+					}
+					fmt.Fprintf(bw, "\n")
+				}
+				fmt.Fprintf(bw, "\n</details>\n")
+			}
+		}
+		if len(pkg.Findings) > 0 {
+			fmt.Fprintf(bw, "\n| Rule | Function | Source | Message |\n")
+			fmt.Fprintf(bw, "|---|---|---|---|\n")
+			for _, finding := range pkg.Findings {
+				fmt.Fprintf(bw, "| %s | `%s` | %s | %s |\n",
+					markdownCell(finding.Rule), markdownCell(finding.Func),
+					markdownCell(finding.Pos.String()), markdownCell(finding.Message))
+			}
+		}
+	}
+	if len(r.Handlings) > 0 {
+		fmt.Fprintf(bw, "\n### Error handling\n\n")
+		fmt.Fprintf(bw, "| Caller | Callee | Source | Checked | Generic |\n")
+		fmt.Fprintf(bw, "|---|---|---|---|---|\n")
+		for _, h := range r.Handlings {
+			checked := make([]string, len(h.Checked))
+			for i, e := range h.Checked {
+				checked[i] = fmt.Sprintf("`%s` (%s)", strings.ReplaceAll(e.Error, "`", "'"), e.Check.Kind)
+			}
+			generic := make([]string, len(h.Generic))
+			for i, e := range h.Generic {
+				generic[i] = "`" + strings.ReplaceAll(e, "`", "'") + "`"
+			}
+			fmt.Fprintf(bw, "| `%s` | `%s` | %s | %s | %s |\n", markdownCell(shortName(h.Caller)), markdownCell(shortName(h.Callee)),
+				markdownCell(h.Pos.String()), markdownCell(strings.Join(checked, "<br>")), markdownCell(strings.Join(generic, "<br>")))
+		}
+	}
+	if len(r.ErrorTypes) > 0 {
+		fmt.Fprintf(bw, "\n### Error types\n\n")
+		fmt.Fprintf(bw, "| Type | Source | Methods | Returned by |\n")
+		fmt.Fprintf(bw, "|---|---|---|---|\n")
+		for _, t := range r.ErrorTypes {
+			methods := make([]string, len(t.Methods))
+			for i, m := range t.Methods {
+				methods[i] = "`" + m.String() + "`" + mismatchNote(t, m)
+			}
+			returners := make([]string, len(t.Returners))
+			for i, fn := range t.Returners {
+				returners[i] = "`" + shortName(fn) + "`"
+			}
+			fmt.Fprintf(bw, "| `%s` | %s | %s | %s |\n", markdownCell(shortName(t.Type)), markdownCell(t.Pos.String()),
+				markdownCell(strings.Join(methods, "<br>")), markdownCell(strings.Join(returners, "<br>")))
+		}
+	}
+	for _, report := range r.HTTPStatuses() {
+		fmt.Fprintf(bw, "\n### HTTP statuses of `%s`\n\n", markdownCell(report.Mapping.Name()))
+		fmt.Fprintf(bw, "| Status | Codes | Endpoints |\n")
+		fmt.Fprintf(bw, "|---|---|---|\n")
+		for _, e := range report.Statuses {
+			endpoints := make([]string, len(e.Endpoints))
+			for i, endpoint := range e.Endpoints {
+				endpoints[i] = "`" + endpoint + "`"
+			}
+			fmt.Fprintf(bw, "| `%s` | %s | %s |\n", markdownCell(statusName(e)),
+				markdownCell(strings.Join(e.Codes, ", ")), markdownCell(strings.Join(endpoints, "<br>")))
+		}
+	}
+	return bw.Flush()
+}
+
+// funcNote marks test and generated functions.
+func funcNote(agError *AggregatedError) string {
+	switch {
+	case agError.Test:
+		return " (test)"
+	case agError.Generated:
+		return " (generated)"
+	}
+	return ""
+}
+
+// markdownCell escapes s for use inside a markdown table cell.
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+// markdownHTML escapes s for use inside the raw HTML of a markdown document.
+func markdownHTML(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
@@ -0,0 +1,28 @@
+package errauditor
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteMarkdown(t *testing.T) {
+	result := runSource(t, "example.com/project", usecaseSrc)
+	result.Findings = append(result.Findings, &Finding{
+		Rule:    "example",
+		Package: "example.com/project",
+		Func:    "GetAddressByUser",
+		Message: "a | b",
+	})
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteMarkdown(&buf, result))
+
+	md := buf.String()
+	require.Contains(t, md, "### `example.com/project`")
+	require.Contains(t, md, "| `GetAddressByUser` | usecase.go:12:1 | 2 | 1 | 1 |")
+	require.Contains(t, md, "<summary><code>GetAddressByUser</code></summary>")
+	require.Contains(t, md, "- `ErrRecordNotFound()`")
+	require.Contains(t, md, `| example | `+"`GetAddressByUser`"+` | - | a \| b |`)
+}
@@ -0,0 +1,193 @@
+package errauditor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OpenAPI is an OpenAPI 3 fragment holding the error responses of the
+// operations registered by a router, to be merged into an API spec.
+type OpenAPI struct {
+	Paths map[string]map[string]*OpenAPIOperation `json:"paths"`
+}
+
+// OpenAPIOperation is an operation with its error responses keyed by status
+// code, or `default` when the status is decided at run time.
+type OpenAPIOperation struct {
+	Handler   string                      `json:"x-handler"`
+	Responses map[string]*OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPIResponse lists the error codes returned with a status.
+type OpenAPIResponse struct {
+	Description string             `json:"description"`
+	ErrorCodes  []OpenAPIErrorCode `json:"x-error-codes"`
+}
+
+// OpenAPIErrorCode is an error code constant with the message of the error
+// constructed with it.
+type OpenAPIErrorCode struct {
+	Name    string `json:"name"`
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// Lookup returns the declarations named by a reference such as
+// `project.GetUser`, `project.(*Server).Routes` or a fully qualified
+// `example.com/project.GetUser`. Pointer and value receivers are
+// interchangeable.
+func (r *Result) Lookup(name string) []*Decl {
+	normalize := strings.NewReplacer("(*", "", "(", "", ")", "").Replace
+	name = normalize(name)
+	var decls []*Decl
+	for key, d := range r.Decls {
+		short := packageName(d.Package) + strings.TrimPrefix(key, d.Package)
+		key = normalize(key)
+		if key == name || strings.HasSuffix(key, "/"+name) || normalize(short) == name {
+			decls = append(decls, d)
+		}
+	}
+	sort.Slice(decls, func(i, j int) bool {
+		return decls[i].Key() < decls[j].Key()
+	})
+	return decls
+}
+
+// OpenAPI generates the error responses of the operations registered by the
+// routers and by the functions they call. Routes without a method are
+// documented as `get` operations.
+func (r *Result) OpenAPI(routers ...string) (*OpenAPI, error) {
+	graph := newCallGraph(r.Decls)
+	var routes []*Route
+	visited := make(map[*Decl]bool)
+	var visit func(d *Decl)
+	visit = func(d *Decl) {
+		if visited[d] {
+			return
+		}
+		visited[d] = true
+		routes = append(routes, d.Routes...)
+		for _, call := range d.Calls {
+			if callee := graph.resolve(call); callee != nil {
+				visit(callee)
+			}
+		}
+	}
+	for _, router := range routers {
+		decls := r.Lookup(router)
+		if len(decls) == 0 {
+			return nil, fmt.Errorf("router %s not found", router)
+		}
+		for _, d := range decls {
+			visit(d)
+		}
+	}
+
+	codes := make(map[string]*Constant)
+	mappings := make(map[string]*StatusMapping)
+	for _, m := range r.StatusMappings {
+		for name, c := range r.mappingCodes(m) {
+			codes[name] = c
+			mappings[name] = m
+		}
+	}
+	sets := &codeSets{graph: graph, codes: codes, memo: make(map[string]map[string]bool)}
+	messages := r.codeMessages(codes)
+
+	doc := &OpenAPI{Paths: make(map[string]map[string]*OpenAPIOperation)}
+	for _, route := range routes {
+		if route.Handler == nil {
+			continue
+		}
+		handler := graph.resolve(route.Handler)
+		if handler == nil {
+			continue
+		}
+		method := strings.ToLower(route.Method)
+		if method == "" {
+			method = "get"
+		}
+		op := &OpenAPIOperation{Handler: handler.Key(), Responses: make(map[string]*OpenAPIResponse)}
+		defaultCode := r.defaultStatus(handler, graph)
+		handled := sets.handled(handler)
+		names := make([]string, 0, len(handled))
+		for name := range handled {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			return positionLess(codes[names[i]].Pos, codes[names[j]].Pos)
+		})
+		for _, name := range names {
+			m := mappings[name]
+			status, ok := m.Statuses[name]
+			if !ok {
+				status = m.Default
+			}
+			// constants are resolved, parameters get the status the handler
+			// passes.
+			status = r.statusText(m, status)
+			code := statusCode(status)
+			if strings.HasPrefix(status, "passed as ") {
+				code = defaultCode
+			}
+			key, description := "default", "Errors whose status is decided by the caller of "+mappings[name].Name()
+			if code != 0 {
+				key, description = strconv.Itoa(code), http.StatusText(code)
+			}
+			resp, ok := op.Responses[key]
+			if !ok {
+				resp = &OpenAPIResponse{Description: description}
+				op.Responses[key] = resp
+			}
+			resp.ErrorCodes = append(resp.ErrorCodes, OpenAPIErrorCode{
+				Name:    name,
+				Code:    codes[name].Value,
+				Message: messages[name],
+			})
+		}
+		if doc.Paths[route.Path] == nil {
+			doc.Paths[route.Path] = make(map[string]*OpenAPIOperation)
+		}
+		doc.Paths[route.Path][method] = op
+	}
+	return doc, nil
+}
+
+// codeMessages returns the message of the first error constructed with each
+// code, in file order.
+func (r *Result) codeMessages(codes map[string]*Constant) map[string]string {
+	messages := make(map[string]string)
+	first := make(map[string]*Call)
+	for _, d := range r.Decls {
+		for _, call := range d.Calls {
+			var message string
+			if c := call.Construction; c != nil && c.Message != "" {
+				message, _ = strconv.Unquote(c.Message)
+			} else if len(call.Literals) > 0 {
+				message = call.Literals[0]
+			}
+			if message == "" {
+				continue
+			}
+			for _, name := range callCodes(call, codes) {
+				if prev, ok := first[name]; !ok || positionLess(call.Pos, prev.Pos) {
+					first[name] = call
+					messages[name] = message
+				}
+			}
+		}
+	}
+	return messages
+}
+
+// WriteOpenAPI writes the OpenAPI fragment as indented JSON.
+func WriteOpenAPI(w io.Writer, doc *OpenAPI) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
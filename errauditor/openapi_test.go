@@ -0,0 +1,110 @@
+package errauditor
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const routerSrc = `package project
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/mux"
+)
+
+func NewRouter(u *usecase) http.Handler {
+	sm := http.NewServeMux()
+	sm.HandleFunc("GET /users/{id}", u.GetHandler)
+	sm.Handle("/ping", http.HandlerFunc(u.PingHandler))
+	registerAdmin(u)
+	return sm
+}
+
+func registerAdmin(u *usecase) {
+	r := gin.New()
+	v1 := r.Group("/v1")
+	v1.DELETE("/users/:id", auth(u.GetHandler))
+	m := mux.NewRouter()
+	api := m.PathPrefix("/api").Subrouter()
+	api.HandleFunc("/users/{id:[0-9]+}", u.GetHandler).Methods("GET", "HEAD")
+}
+
+func auth(h gin.HandlerFunc) gin.HandlerFunc { return h }
+`
+
+func TestRoutes(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "router.go", routerSrc, 0)
+	require.NoError(t, err)
+	auditor := NewAuditor()
+	require.NoError(t, auditor.Run("example.com/project", f, fset))
+
+	var routes []string
+	for _, name := range []string{"NewRouter", "registerAdmin"} {
+		for _, route := range auditor.Result().Decls["example.com/project."+name].Routes {
+			routes = append(routes, route.Method+" "+route.Path+" "+route.Handler.Name)
+		}
+	}
+	require.Equal(t, []string{
+		"GET /users/{id} GetHandler",
+		" /ping PingHandler",
+		"DELETE /v1/users/{id} GetHandler",
+		"GET /api/users/{id} GetHandler",
+		"HEAD /api/users/{id} GetHandler",
+	}, routes)
+}
+
+func TestOpenAPI(t *testing.T) {
+	auditor := NewAuditor()
+	fset := token.NewFileSet()
+	for _, file := range []struct{ pkgPath, name, src string }{
+		{"example.com/project/pkg/apperrors", "errors.go", apperrorsSrc},
+		{"example.com/project", "handler.go", handlerSrc},
+		{"example.com/project", "router.go", routerSrc},
+	} {
+		f, err := parser.ParseFile(fset, file.name, file.src, 0)
+		require.NoError(t, err)
+		require.NoError(t, auditor.Run(file.pkgPath, f, fset))
+	}
+	result := auditor.Result()
+
+	_, err := result.OpenAPI("project.Missing")
+	require.Error(t, err)
+
+	doc, err := result.OpenAPI("project.NewRouter")
+	require.NoError(t, err)
+	require.Equal(t, []string{"/api/users/{id}", "/ping", "/users/{id}", "/v1/users/{id}"}, sortedKeys(doc.Paths))
+
+	op := doc.Paths["/users/{id}"]["get"]
+	require.Equal(t, "example.com/project.(*usecase).GetHandler", op.Handler)
+	require.Equal(t, map[string]*OpenAPIResponse{
+		"404": {Description: "Not Found", ErrorCodes: []OpenAPIErrorCode{
+			{Name: "CodeRecordNotFound", Code: "RecordNotFound", Message: "record not found"},
+		}},
+		"default": {Description: "Errors whose status is decided by the caller of DomainError.HTTPStatusCode", ErrorCodes: []OpenAPIErrorCode{
+			{Name: "CodeInternalError", Code: "InternalError"},
+		}},
+	}, op.Responses)
+	require.Empty(t, doc.Paths["/ping"]["get"].Responses)
+	require.Contains(t, doc.Paths["/api/users/{id}"], "head")
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteOpenAPI(&buf, doc))
+	require.Contains(t, buf.String(), `"x-handler": "example.com/project.(*usecase).GetHandler"`)
+}
+
+func sortedKeys(paths map[string]map[string]*OpenAPIOperation) []string {
+	var keys []string
+	for key := range paths {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
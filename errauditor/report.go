@@ -0,0 +1,183 @@
+package errauditor
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// PackageReport groups the aggregated errors and findings of a single package.
+type PackageReport struct {
+	Package           string
+	Funcs             []*AggregatedError
+	Findings          []*Finding
+	WrappedErrorCount int64
+	ConstErrorCount   int64
+}
+
+// Packages groups the result by package, sorted by package path.
+func (r *Result) Packages() []*PackageReport {
+	byPath := make(map[string]*PackageReport)
+	get := func(path string) *PackageReport {
+		pkg, ok := byPath[path]
+		if !ok {
+			pkg = &PackageReport{Package: path}
+			byPath[path] = pkg
+		}
+		return pkg
+	}
+	for _, agError := range r.AggregatedErrors {
+		pkg := get(agError.Package)
+		pkg.Funcs = append(pkg.Funcs, agError)
+		pkg.WrappedErrorCount += agError.WrappedErrorCount
+		pkg.ConstErrorCount += agError.ConstErrorCount
+	}
+	for _, finding := range r.Findings {
+		pkg := get(finding.Package)
+		pkg.Findings = append(pkg.Findings, finding)
+	}
+
+	pkgs := make([]*PackageReport, 0, len(byPath))
+	for _, pkg := range byPath {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Slice(pkgs, func(i, j int) bool {
+		return pkgs[i].Package < pkgs[j].Package
+	})
+	return pkgs
+}
+
+// WriteText writes the result as colored terminal output.
+func WriteText(w io.Writer, r *Result) error {
+	white := color.New(color.FgWhite)
+	red := color.New(color.FgRed)
+	yellow := color.New(color.FgYellow)
+
+	for _, entry := range r.EntryPoints {
+		if _, err := white.Fprintf(w, "%s:  %s entry point\n", entry.Decl.Pos, shortName(entry.Decl.Key())); err != nil {
+			return err
+		}
+		for _, e := range entry.Errors {
+			if _, err := red.Fprintf(w, "---%s \n", e.Error); err != nil {
+				return err
+			}
+			if err := writeCauses(w, red, e.Entry, "   "); err != nil {
+				return err
+			}
+			if _, err := white.Fprintf(w, "      %s\n", traceString(e.Trace)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, agError := range r.AggregatedErrors {
+		if _, err := white.Fprintf(w, "%s:  %s%s\n", agError.Pos, agError.Func, funcNote(agError)); err != nil {
+			return err
+		}
+		for _, e := range agError.traced() {
+			if _, err := red.Fprintf(w, "---%s \n", e.Error); err != nil {
+				return err
+			}
+			if err := writeCauses(w, red, e.Entry, "   "); err != nil {
+				return err
+			}
+			if len(e.Trace) == 0 {
+				continue
+			}
+			if _, err := white.Fprintf(w, "      %s\n", traceString(e.Trace)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, finding := range r.Findings {
+		if _, err := yellow.Fprintf(w, "%s: %s: %s\n", finding.Pos, finding.Rule, finding.Message); err != nil {
+			return err
+		}
+	}
+	for _, h := range r.Handlings {
+		if _, err := white.Fprintf(w, "%s:  %s calls %s\n", h.Pos, shortName(h.Caller), shortName(h.Callee)); err != nil {
+			return err
+		}
+		for _, e := range h.Checked {
+			if _, err := red.Fprintf(w, "---%s %s \n", e.Check.Kind, e.Error); err != nil {
+				return err
+			}
+		}
+		for _, e := range h.Generic {
+			if _, err := red.Fprintf(w, "---generic %s \n", e); err != nil {
+				return err
+			}
+		}
+	}
+	for _, t := range r.ErrorTypes {
+		if _, err := white.Fprintf(w, "%s:  %s error type\n", t.Pos, shortName(t.Type)); err != nil {
+			return err
+		}
+		for _, m := range t.Methods {
+			if _, err := red.Fprintf(w, "---%s%s \n", m, mismatchNote(t, m)); err != nil {
+				return err
+			}
+		}
+		if len(t.Returners) == 0 {
+			continue
+		}
+		returners := make([]string, len(t.Returners))
+		for i, fn := range t.Returners {
+			returners[i] = shortName(fn)
+		}
+		if _, err := white.Fprintf(w, "      returned by %s\n", strings.Join(returners, ", ")); err != nil {
+			return err
+		}
+	}
+	for _, report := range r.HTTPStatuses() {
+		if _, err := white.Fprintf(w, "%s:  %s statuses\n", report.Mapping.Pos, report.Mapping.Name()); err != nil {
+			return err
+		}
+		for _, e := range report.Statuses {
+			if _, err := red.Fprintf(w, "---%s: %s\n", statusName(e), strings.Join(e.Codes, ", ")); err != nil {
+				return err
+			}
+			for _, endpoint := range e.Endpoints {
+				if _, err := white.Fprintf(w, "      %s\n", endpoint); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// writeCauses writes the tree of the errors wrapped by an error.
+func writeCauses(w io.Writer, c *color.Color, e *ErrorEntry, indent string) error {
+	if e == nil {
+		return nil
+	}
+	for _, cause := range e.Causes {
+		if _, err := c.Fprintf(w, "%s+--%s \n", indent, cause); err != nil {
+			return err
+		}
+		if err := writeCauses(w, c, cause, indent+"   "); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mismatchNote marks the methods whose receiver differs from the receiver of
+// Error.
+func mismatchNote(t *CustomErrorType, m *ErrorMethod) string {
+	if m.Pointer == t.Pointer {
+		return ""
+	}
+	return fmt.Sprintf(" (%s receiver, Error has a %s receiver)", m.receiver(), (&ErrorMethod{Pointer: t.Pointer}).receiver())
+}
+
+// statusName renders the status of an entry, marking the default clause.
+func statusName(e *StatusEntry) string {
+	if e.Default {
+		return "default " + e.Status
+	}
+	return e.Status
+}
@@ -0,0 +1,207 @@
+package errauditor
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+)
+
+// Route is an HTTP route registered by a function, such as
+// `r.GET("/users/:id", h.GetUser)` or `mux.HandleFunc("GET /users/{id}", getUser)`.
+type Route struct {
+	Pos token.Position
+	// Method is the upper case HTTP method, empty when the route matches
+	// any method.
+	Method string
+	// Path is the path template in OpenAPI syntax, such as `/users/{id}`.
+	Path string
+	// Handler is the function handling the route, nil for function literals.
+	Handler *Call
+}
+
+var httpMethods = map[string]bool{
+	"GET":     true,
+	"HEAD":    true,
+	"POST":    true,
+	"PUT":     true,
+	"PATCH":   true,
+	"DELETE":  true,
+	"OPTIONS": true,
+}
+
+// notRoutes are the methods of routers taking a path that do not register a
+// handler.
+var notRoutes = map[string]bool{
+	"Group":      true,
+	"PathPrefix": true,
+	"Route":      true,
+	"Mount":      true,
+	"Static":     true,
+}
+
+// routes returns the routes registered in node. prefixes maps the variables
+// holding route groups, such as `v1 := r.Group("/v1")`, to their path prefix.
+func (w *walker) routes(node ast.Node, prefixes map[string]string, recv, recvName string) []*Route {
+	var routes []*Route
+	prefixes = copyPrefixes(prefixes)
+	// gorilla/mux routes are registered by the call chained to `.Methods`.
+	chained := make(map[*ast.CallExpr]bool)
+	ast.Inspect(node, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.AssignStmt:
+			if len(node.Lhs) == 1 && len(node.Rhs) == 1 {
+				ident, ok := node.Lhs[0].(*ast.Ident)
+				if base, prefix, ok2 := groupPrefix(node.Rhs[0]); ok && ok2 {
+					prefixes[ident.Name] = prefixes[base] + prefix
+				}
+			}
+		case *ast.CallExpr:
+			sel, ok := node.Fun.(*ast.SelectorExpr)
+			if !ok || chained[node] {
+				return true
+			}
+			if sel.Sel.Name == "Methods" {
+				if inner, ok := sel.X.(*ast.CallExpr); ok {
+					if route := w.route(inner, prefixes, recv, recvName); route != nil {
+						chained[inner] = true
+						for _, arg := range node.Args {
+							if method, ok := stringLit(arg); ok {
+								r := *route
+								r.Method = strings.ToUpper(method)
+								routes = append(routes, &r)
+							}
+						}
+					}
+				}
+				return true
+			}
+			// chi registers sub-routes in a function literal,
+			// `r.Route("/users", func(r chi.Router) { ... })`.
+			if len(node.Args) == 2 {
+				path, ok := stringLit(node.Args[0])
+				lit, ok2 := node.Args[1].(*ast.FuncLit)
+				if ok && ok2 && strings.HasPrefix(path, "/") && len(lit.Type.Params.List) > 0 && len(lit.Type.Params.List[0].Names) > 0 {
+					sub := copyPrefixes(prefixes)
+					sub[lit.Type.Params.List[0].Names[0].Name] = receiverPrefix(sel.X, prefixes) + path
+					routes = append(routes, w.routes(lit.Body, sub, recv, recvName)...)
+					return false
+				}
+			}
+			if route := w.route(node, prefixes, recv, recvName); route != nil {
+				routes = append(routes, route)
+			}
+		}
+		return true
+	})
+	return routes
+}
+
+func copyPrefixes(prefixes map[string]string) map[string]string {
+	copied := make(map[string]string, len(prefixes))
+	for name, prefix := range prefixes {
+		copied[name] = prefix
+	}
+	return copied
+}
+
+// groupPrefix returns the group and the path prefix of a route group, such as
+// `r.Group("/v1")` or `r.PathPrefix("/v1").Subrouter()`.
+func groupPrefix(expr ast.Expr) (string, string, bool) {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return "", "", false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return "", "", false
+	}
+	if sel.Sel.Name == "Subrouter" {
+		return groupPrefix(sel.X)
+	}
+	if sel.Sel.Name != "Group" && sel.Sel.Name != "PathPrefix" || len(call.Args) == 0 {
+		return "", "", false
+	}
+	prefix, ok := stringLit(call.Args[0])
+	x, ok2 := sel.X.(*ast.Ident)
+	if !ok || !ok2 || !strings.HasPrefix(prefix, "/") {
+		return "", "", false
+	}
+	return x.Name, strings.TrimSuffix(prefix, "/"), true
+}
+
+func receiverPrefix(expr ast.Expr, prefixes map[string]string) string {
+	if x, ok := expr.(*ast.Ident); ok {
+		return prefixes[x.Name]
+	}
+	return ""
+}
+
+// route returns the route registered by a call with a path literal, an
+// optional method and a handler as its last argument.
+func (w *walker) route(call *ast.CallExpr, prefixes map[string]string, recv, recvName string) *Route {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || len(call.Args) < 2 || notRoutes[sel.Sel.Name] {
+		return nil
+	}
+	if x, ok := sel.X.(*ast.Ident); ok && w.imports[x.Name] != "" && w.imports[x.Name] != "net/http" {
+		// package functions only register routes of net/http.
+		return nil
+	}
+	args := call.Args
+	var method string
+	if m := strings.ToUpper(sel.Sel.Name); httpMethods[m] {
+		method = m
+	} else if m, ok := stringLit(args[0]); ok && httpMethods[m] {
+		method, args = m, args[1:]
+	}
+	path, ok := stringLit(args[0])
+	if !ok || len(args) < 2 {
+		return nil
+	}
+	if i := strings.IndexByte(path, ' '); i > 0 && httpMethods[path[:i]] {
+		// net/http patterns of Go 1.22, `GET /users/{id}`.
+		method, path = path[:i], strings.TrimSpace(path[i+1:])
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil
+	}
+	route := &Route{
+		Pos:    w.fset.Position(call.Pos()),
+		Method: method,
+		Path:   openAPIPath(receiverPrefix(sel.X, prefixes) + path),
+	}
+	handler := args[len(args)-1]
+	for {
+		// unwrap `http.HandlerFunc(h)` and middlewares such as `auth(h)`.
+		c, ok := handler.(*ast.CallExpr)
+		if !ok || len(c.Args) == 0 {
+			break
+		}
+		handler = c.Args[len(c.Args)-1]
+	}
+	switch handler.(type) {
+	case *ast.Ident, *ast.SelectorExpr:
+		route.Handler = w.call(handler, recv, recvName)
+		route.Handler.Pos = w.fset.Position(handler.Pos())
+	}
+	return route
+}
+
+// openAPIPath converts the path parameters of the common routers, `:id`,
+// `*path`, `{id:[0-9]+}` and `{path...}`, to OpenAPI path templates.
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		switch {
+		case strings.HasPrefix(segment, ":"), strings.HasPrefix(segment, "*") && len(segment) > 1:
+			segments[i] = "{" + segment[1:] + "}"
+		case strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}"):
+			name := strings.TrimSuffix(segment[1:len(segment)-1], "...")
+			if j := strings.IndexByte(name, ':'); j >= 0 {
+				name = name[:j]
+			}
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,491 @@
+package errauditor
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// HTTPStatusRule is reported for HTTP handlers receiving error codes that fall
+// through to the default status of an HTTP status mapping.
+const HTTPStatusRule = "http-status-default"
+
+// statusMethod is the method mapping the code of a domain error to an HTTP
+// status, such as `DomainError.HTTPStatusCode`.
+const statusMethod = "HTTPStatusCode"
+
+// Constant is a typed package level constant.
+type Constant struct {
+	Package string
+	// Type is the qualified name of the constant type.
+	Type string
+	// Value is the value of the constant, unquoted for string literals.
+	Value string
+	Pos   token.Position
+}
+
+// StatusMapping is the switch statement of an HTTP status mapping method,
+// evaluated statically.
+type StatusMapping struct {
+	Package string
+	Recv    string
+	Pos     token.Position
+	// Statuses maps the codes listed in case clauses to the expression of the
+	// returned status, such as `http.StatusNotFound`.
+	Statuses map[string]string
+	// Cases lists the qualified names of the codes of the case clauses, in
+	// order, resolved through the imports of the file.
+	Cases []string
+	// Default is the status returned by the default clause.
+	Default string
+	// Params holds the parameters of the method, whose status is passed by
+	// its caller when a clause returns one.
+	Params []string
+}
+
+// Name returns the method name qualified by its receiver type.
+func (m *StatusMapping) Name() string {
+	return (&AggregatedError{Recv: m.Recv, Func: statusMethod}).Name()
+}
+
+// StatusReport lists, for each status of a mapping, the error codes mapped to
+// it and the HTTP handlers that can produce it.
+type StatusReport struct {
+	Mapping  *StatusMapping
+	Statuses []*StatusEntry
+}
+
+// StatusEntry is a status of a mapping. The entry of the default clause holds
+// the codes that are not listed in any case clause.
+type StatusEntry struct {
+	Status    string
+	Default   bool
+	Codes     []string
+	Endpoints []string
+}
+
+// statusMapping evaluates the first switch statement of a status mapping
+// method, it returns nil when the method has none.
+func statusMapping(pkgPath string, imports map[string]string, decl *ast.FuncDecl, fset *token.FileSet) *StatusMapping {
+	var sw *ast.SwitchStmt
+	ast.Inspect(decl.Body, func(node ast.Node) bool {
+		if s, ok := node.(*ast.SwitchStmt); ok && sw == nil {
+			sw = s
+		}
+		return sw == nil
+	})
+	if sw == nil {
+		return nil
+	}
+	m := &StatusMapping{
+		Package:  pkgPath,
+		Recv:     recvTypeName(decl.Recv.List[0].Type),
+		Pos:      fset.Position(decl.Pos()),
+		Statuses: make(map[string]string),
+	}
+	for _, field := range decl.Type.Params.List {
+		for _, name := range field.Names {
+			m.Params = append(m.Params, name.Name)
+		}
+	}
+	for _, stmt := range sw.Body.List {
+		clause := stmt.(*ast.CaseClause)
+		var status string
+		for _, s := range clause.Body {
+			if ret, ok := s.(*ast.ReturnStmt); ok && len(ret.Results) > 0 {
+				status = types.ExprString(ret.Results[0])
+				break
+			}
+		}
+		if clause.List == nil {
+			m.Default = status
+			continue
+		}
+		for _, expr := range clause.List {
+			if name := identName(expr); name != "" {
+				m.Statuses[name] = status
+				if qualified := qualifiedName(pkgPath, imports, expr); qualified != "" {
+					m.Cases = append(m.Cases, qualified)
+				}
+			}
+		}
+	}
+	return m
+}
+
+// identName returns the name of an identifier without its package qualifier.
+func identName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		return expr.Name
+	case *ast.SelectorExpr:
+		return expr.Sel.Name
+	}
+	return ""
+}
+
+// statusText renders a status returned by a mapping: package level constants
+// are resolved to their value, and parameters to the status passed by the
+// caller.
+func (r *Result) statusText(m *StatusMapping, status string) string {
+	for _, param := range m.Params {
+		if status == param {
+			return "passed as " + param
+		}
+	}
+	if c, ok := r.Constants[m.Package+"."+status]; ok && c.Value != "" {
+		return c.Value
+	}
+	return status
+}
+
+// defaultStatusText renders the default status of a mapping for a handler,
+// with the status the handler passes to the mapping when it is known.
+func (r *Result) defaultStatusText(m *StatusMapping, handler *Decl, graph *callGraph) string {
+	status := r.statusText(m, m.Default)
+	if !strings.HasPrefix(status, "passed as ") {
+		return status
+	}
+	if code := r.defaultStatus(handler, graph); code != 0 {
+		return strconv.Itoa(code)
+	}
+	return status
+}
+
+// defaultStatus returns the status a handler, or a function it calls, passes
+// to a status mapping method for unmapped codes, and 0 when it is unknown.
+func (r *Result) defaultStatus(handler *Decl, graph *callGraph) int {
+	decls := []*Decl{handler}
+	for _, call := range handler.Calls {
+		if callee := graph.resolve(call); callee != nil {
+			decls = append(decls, callee)
+		}
+	}
+	for _, d := range decls {
+		for _, call := range d.Calls {
+			if call.Name != statusMethod || !call.Method {
+				continue
+			}
+			for _, ident := range call.Idents {
+				if code := statusCode(ident); code != 0 {
+					return code
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// httpStatuses maps the status constants of net/http to their code.
+var httpStatuses = func() map[string]int {
+	statuses := map[string]int{
+		"StatusNonAuthoritativeInfo":         http.StatusNonAuthoritativeInfo,
+		"StatusTeapot":                       http.StatusTeapot,
+		"StatusRequestEntityTooLarge":        http.StatusRequestEntityTooLarge,
+		"StatusRequestURITooLong":            http.StatusRequestURITooLong,
+		"StatusRequestedRangeNotSatisfiable": http.StatusRequestedRangeNotSatisfiable,
+		"StatusUnprocessableEntity":          http.StatusUnprocessableEntity,
+	}
+	for code := 100; code < 600; code++ {
+		text := http.StatusText(code)
+		if text == "" {
+			continue
+		}
+		// the constants are named after their status text.
+		name := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return r
+			}
+			return -1
+		}, text)
+		statuses["Status"+name] = code
+	}
+	return statuses
+}()
+
+// statusCode returns the code of a status expression such as
+// `http.StatusNotFound` or `404`, and 0 when it is not constant.
+func statusCode(expr string) int {
+	if code, err := strconv.Atoi(expr); err == nil {
+		return code
+	}
+	return httpStatuses[expr[strings.LastIndex(expr, ".")+1:]]
+}
+
+// stringLit returns the value of a string literal.
+func stringLit(expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
+
+// qualifiedName returns the name of an identifier qualified by the import path
+// of its package, or "" for a selector on something else than an import.
+func qualifiedName(pkgPath string, imports map[string]string, expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		return pkgPath + "." + expr.Name
+	case *ast.SelectorExpr:
+		if x, ok := expr.X.(*ast.Ident); ok && imports[x.Name] != "" {
+			return imports[x.Name] + "." + expr.Sel.Name
+		}
+	}
+	return ""
+}
+
+// addConstants records the typed constants of a const declaration. Specs
+// without a type and value repeat the type of the previous spec.
+func (r *Result) addConstants(pkgPath string, decl *ast.GenDecl, fset *token.FileSet) {
+	var typ string
+	for _, spec := range decl.Specs {
+		spec := spec.(*ast.ValueSpec)
+		switch {
+		case spec.Type != nil:
+			typ = types.ExprString(spec.Type)
+			if !strings.Contains(typ, ".") {
+				typ = pkgPath + "." + typ
+			}
+		case spec.Values != nil:
+			typ = ""
+		}
+		if typ == "" {
+			continue
+		}
+		if r.Constants == nil {
+			r.Constants = make(map[string]*Constant)
+		}
+		for i, ident := range spec.Names {
+			c := &Constant{Package: pkgPath, Type: typ, Pos: fset.Position(ident.Pos())}
+			if i < len(spec.Values) {
+				var ok bool
+				if c.Value, ok = stringLit(spec.Values[i]); !ok {
+					c.Value = types.ExprString(spec.Values[i])
+				}
+			}
+			r.Constants[pkgPath+"."+ident.Name] = c
+		}
+	}
+}
+
+// mappingCodes returns the constants of the code type of a mapping by name.
+// The code type is the type of the first audited constant listed in its case
+// clauses.
+func (r *Result) mappingCodes(m *StatusMapping) map[string]*Constant {
+	for _, name := range m.Cases {
+		if c, ok := r.Constants[name]; ok {
+			return r.typeConstants(c.Type)
+		}
+	}
+	return make(map[string]*Constant)
+}
+
+// typeConstants returns the constants of a type by name. Constants sharing a
+// name keep the first one by qualified name.
+func (r *Result) typeConstants(typ string) map[string]*Constant {
+	codes := make(map[string]*Constant)
+	keys := make([]string, 0, len(r.Constants))
+	for key := range r.Constants {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		name := key[strings.LastIndex(key, ".")+1:]
+		if c := r.Constants[key]; c.Type == typ && codes[name] == nil {
+			codes[name] = c
+		}
+	}
+	return codes
+}
+
+// callCodes returns the codes passed to a call.
+func callCodes(call *Call, codes map[string]*Constant) []string {
+	var found []string
+	if c := call.Construction; c != nil && c.Code != "" {
+		if name := c.Code[strings.LastIndex(c.Code, ".")+1:]; codes[name] != nil {
+			return []string{name}
+		}
+	}
+	for _, ident := range call.Idents {
+		if codes[ident] != nil {
+			found = append(found, ident)
+		}
+	}
+	return found
+}
+
+// codeSets computes the codes declarations may return, following returned
+// calls and references through the call graph.
+type codeSets struct {
+	graph *callGraph
+	codes map[string]*Constant
+	memo  map[string]map[string]bool
+}
+
+func (s *codeSets) returned(d *Decl) map[string]bool {
+	key := d.Key()
+	if set, ok := s.memo[key]; ok {
+		return set
+	}
+	set := make(map[string]bool)
+	// recursive declarations see their partial set.
+	s.memo[key] = set
+	for _, call := range d.Calls {
+		if call.Returned {
+			s.add(set, call)
+		}
+	}
+	return set
+}
+
+// add adds the codes of a call and of the declaration it refers to.
+func (s *codeSets) add(set map[string]bool, call *Call) {
+	for _, code := range callCodes(call, s.codes) {
+		set[code] = true
+	}
+	if callee := s.graph.resolve(call); callee != nil {
+		for code := range s.returned(callee) {
+			set[code] = true
+		}
+	}
+}
+
+// handled returns the codes of the errors a handler receives from its calls.
+func (s *codeSets) handled(d *Decl) map[string]bool {
+	set := make(map[string]bool)
+	for _, call := range d.Calls {
+		s.add(set, call)
+	}
+	return set
+}
+
+// HTTPStatuses evaluates the HTTP status mappings of the result and reports
+// which handlers can produce each status.
+func (r *Result) HTTPStatuses() []*StatusReport {
+	if len(r.StatusMappings) == 0 {
+		return nil
+	}
+	graph := newCallGraph(r.Decls)
+	var reports []*StatusReport
+	for _, m := range r.StatusMappings {
+		codes := r.mappingCodes(m)
+		sets := &codeSets{graph: graph, codes: codes, memo: make(map[string]map[string]bool)}
+
+		// the default clause keeps its own entry when a case clause returns
+		// the same status.
+		type entryKey struct {
+			status string
+			def    bool
+		}
+		entries := make(map[entryKey]*StatusEntry)
+		entry := func(code string) *StatusEntry {
+			status, ok := m.Statuses[code]
+			if !ok {
+				status = m.Default
+			}
+			status = r.statusText(m, status)
+			key := entryKey{status, !ok}
+			e, found := entries[key]
+			if !found {
+				e = &StatusEntry{Status: status, Default: !ok}
+				entries[key] = e
+			}
+			return e
+		}
+		for code := range codes {
+			e := entry(code)
+			e.Codes = append(e.Codes, code)
+		}
+		for code := range m.Statuses {
+			if codes[code] == nil {
+				// codes of another type or declared outside the audit.
+				e := entry(code)
+				e.Codes = append(e.Codes, code)
+			}
+		}
+		for key, d := range r.Decls {
+			if !d.Handler {
+				continue
+			}
+			seen := make(map[*StatusEntry]bool)
+			for code := range sets.handled(d) {
+				if e := entry(code); !seen[e] {
+					seen[e] = true
+					e.Endpoints = append(e.Endpoints, key)
+				}
+			}
+		}
+
+		report := &StatusReport{Mapping: m}
+		for _, e := range entries {
+			// codes in declaration order, unknown codes last.
+			sort.Slice(e.Codes, func(i, j int) bool {
+				x, y := codes[e.Codes[i]], codes[e.Codes[j]]
+				switch {
+				case x != nil && y != nil:
+					return positionLess(x.Pos, y.Pos)
+				case x != nil || y != nil:
+					return x != nil
+				}
+				return e.Codes[i] < e.Codes[j]
+			})
+			sort.Strings(e.Endpoints)
+			report.Statuses = append(report.Statuses, e)
+		}
+		sort.Slice(report.Statuses, func(i, j int) bool {
+			x, y := report.Statuses[i], report.Statuses[j]
+			if x.Default != y.Default {
+				return y.Default
+			}
+			return x.Status < y.Status
+		})
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// checkHTTPStatuses reports the HTTP handlers whose calls can return codes
+// that fall through to the default status of a mapping, with one finding per
+// handler and mapping. Codes that reach no handler are not reported.
+func (r *Result) checkHTTPStatuses() {
+	if len(r.StatusMappings) == 0 {
+		return
+	}
+	graph := newCallGraph(r.Decls)
+	for _, m := range r.StatusMappings {
+		codes := r.mappingCodes(m)
+		sets := &codeSets{graph: graph, codes: codes, memo: make(map[string]map[string]bool)}
+		for _, d := range r.Decls {
+			if !d.Handler {
+				continue
+			}
+			var unmapped []string
+			for code := range sets.handled(d) {
+				if _, ok := m.Statuses[code]; !ok {
+					unmapped = append(unmapped, code)
+				}
+			}
+			if len(unmapped) == 0 {
+				continue
+			}
+			sort.Slice(unmapped, func(i, j int) bool {
+				return positionLess(codes[unmapped[i]].Pos, codes[unmapped[j]].Pos)
+			})
+			r.Findings = append(r.Findings, &Finding{
+				Rule:    HTTPStatusRule,
+				Package: d.Package,
+				Func:    (&AggregatedError{Recv: d.Recv, Func: d.Name}).Name(),
+				Pos:     d.Pos,
+				Message: fmt.Sprintf("can receive %s, which %s does not map, falling through to the default status %s", strings.Join(unmapped, ", "), m.Name(), r.defaultStatusText(m, d, graph)),
+			})
+		}
+	}
+}
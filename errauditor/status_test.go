@@ -0,0 +1,290 @@
+package errauditor
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const apperrorsSrc = `package apperrors
+
+import "net/http"
+
+type ErrorCode string
+
+type DomainError struct {
+	Code    ErrorCode
+	Message string
+}
+
+func NewDomainError(code ErrorCode, message string) *DomainError {
+	return &DomainError{Code: code, Message: message}
+}
+
+func (e *DomainError) Error() string { return e.Message }
+
+func (e DomainError) HTTPStatusCode(defaultCode int) int {
+	switch e.Code {
+	case CodeRecordNotFound:
+		return http.StatusNotFound
+	case CodeUnauthorized, CodeSessionExpired:
+		return http.StatusUnauthorized
+	default:
+		return defaultCode
+	}
+}
+
+const (
+	CodeUnauthorized   ErrorCode = "Unauthorized"
+	CodeInternalError  ErrorCode = "InternalError"
+	CodeRecordNotFound ErrorCode = "RecordNotFound"
+	CodeSessionExpired ErrorCode = "ExpiredSession"
+	CodeUnused         ErrorCode = "Unused"
+)
+
+var ErrRecordNotFound = NewDomainError(CodeRecordNotFound, "record not found")
+
+func ErrInternalServerError(message string) *DomainError {
+	return NewDomainError(CodeInternalError, message)
+}
+`
+
+const handlerSrc = `package project
+
+import (
+	"net/http"
+
+	"example.com/project/pkg/apperrors"
+)
+
+type repo struct{}
+
+func (r *repo) Find(id string) (string, error) {
+	if id == "" {
+		return "", apperrors.ErrRecordNotFound
+	}
+	return id, nil
+}
+
+type usecase struct {
+	repo *repo
+}
+
+func (u *usecase) Get(id string) (string, error) {
+	user, err := u.repo.Find(id)
+	if err != nil {
+		return "", err
+	}
+	if user == "admin" {
+		return "", apperrors.ErrInternalServerError("admin")
+	}
+	return user, nil
+}
+
+func (u *usecase) GetHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := u.Get(r.URL.Query().Get("id")); err != nil {
+		writeError(w, err)
+	}
+}
+
+func (u *usecase) PingHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeError(w http.ResponseWriter, err error) {}
+`
+
+func auditStatuses(t *testing.T) *Result {
+	t.Helper()
+
+	auditor := NewAuditor()
+	fset := token.NewFileSet()
+	for _, file := range []struct{ pkgPath, name, src string }{
+		{"example.com/project/pkg/apperrors", "errors.go", apperrorsSrc},
+		{"example.com/project", "handler.go", handlerSrc},
+	} {
+		f, err := parser.ParseFile(fset, file.name, file.src, parser.ParseComments)
+		require.NoError(t, err)
+		require.NoError(t, auditor.Run(file.pkgPath, f, fset))
+	}
+	return auditor.Result()
+}
+
+func TestHTTPStatuses(t *testing.T) {
+	result := auditStatuses(t)
+
+	reports := result.HTTPStatuses()
+	require.Len(t, reports, 1)
+	require.Equal(t, "DomainError.HTTPStatusCode", reports[0].Mapping.Name())
+	require.Equal(t, []*StatusEntry{
+		{Status: "http.StatusNotFound", Codes: []string{"CodeRecordNotFound"}, Endpoints: []string{"example.com/project.(*usecase).GetHandler"}},
+		{Status: "http.StatusUnauthorized", Codes: []string{"CodeUnauthorized", "CodeSessionExpired"}},
+		{Status: "passed as defaultCode", Default: true, Codes: []string{"CodeInternalError", "CodeUnused"}, Endpoints: []string{"example.com/project.(*usecase).GetHandler"}},
+	}, reports[0].Statuses)
+
+	// CodeUnused reaches no handler and is not reported.
+	require.Len(t, result.Findings, 1)
+	require.Equal(t, HTTPStatusRule, result.Findings[0].Rule)
+	require.Equal(t, "example.com/project", result.Findings[0].Package)
+	require.Equal(t, "(*usecase).GetHandler", result.Findings[0].Func)
+	require.Equal(t, 33, result.Findings[0].Pos.Line)
+	require.Equal(t, "can receive CodeInternalError, which DomainError.HTTPStatusCode does not map, falling through to the default status passed as defaultCode", result.Findings[0].Message)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteMarkdown(&buf, result))
+	require.Contains(t, buf.String(), "| `default passed as defaultCode` | CodeInternalError, CodeUnused | `example.com/project.(*usecase).GetHandler` |")
+}
+
+func TestCallGraphResolve(t *testing.T) {
+	result := auditStatuses(t)
+	graph := newCallGraph(result.Decls)
+
+	get := result.Decls["example.com/project.(*usecase).Get"]
+	require.NotNil(t, get)
+	var returned []string
+	for _, call := range get.Calls {
+		if !call.Returned {
+			continue
+		}
+		if callee := graph.resolve(call); callee != nil {
+			returned = append(returned, callee.Key())
+		}
+	}
+	require.Equal(t, []string{
+		"example.com/project.(*repo).Find",
+		"example.com/project/pkg/apperrors.ErrInternalServerError",
+	}, returned)
+}
+
+func TestHTTPStatusesImportedCodes(t *testing.T) {
+	auditor := NewAuditor()
+	fset := token.NewFileSet()
+	for _, file := range []struct{ pkgPath, name, src string }{
+		{"example.com/project/pkg/apperrors", "errors.go", `package apperrors
+
+type ErrorCode string
+
+const (
+	CodeConflict ErrorCode = "Conflict"
+	CodeInternal ErrorCode = "Internal"
+)
+`},
+		// a constant of the same name and another type.
+		{"example.com/project/pkg/legacy", "codes.go", `package legacy
+
+type Code int
+
+const CodeConflict Code = 409
+`},
+		{"example.com/project", "status.go", `package project
+
+import (
+	"net/http"
+
+	errs "example.com/project/pkg/apperrors"
+)
+
+type Error struct {
+	Code errs.ErrorCode
+}
+
+func (e Error) HTTPStatusCode() int {
+	switch e.Code {
+	case errs.CodeConflict:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusInternalServerError
+	}
+}
+`},
+	} {
+		f, err := parser.ParseFile(fset, file.name, file.src, parser.ParseComments)
+		require.NoError(t, err)
+		require.NoError(t, auditor.Run(file.pkgPath, f, fset))
+	}
+	result := auditor.Result()
+
+	reports := result.HTTPStatuses()
+	require.Len(t, reports, 1)
+	require.Equal(t, []string{"example.com/project/pkg/apperrors.CodeConflict"}, reports[0].Mapping.Cases)
+	// the default clause keeps its own entry although it returns the same
+	// status as the case clause.
+	require.Equal(t, []*StatusEntry{
+		{Status: "http.StatusInternalServerError", Codes: []string{"CodeConflict"}},
+		{Status: "http.StatusInternalServerError", Default: true, Codes: []string{"CodeInternal"}},
+	}, reports[0].Statuses)
+}
+
+func TestHTTPStatusDefaults(t *testing.T) {
+	auditor := NewAuditor()
+	fset := token.NewFileSet()
+	for _, file := range []struct{ pkgPath, name, src string }{
+		{"example.com/project/pkg/apperrors", "errors.go", apperrorsSrc},
+		{"example.com/project", "handler.go", handlerSrc},
+		{"example.com/project", "status.go", `package project
+
+import (
+	"errors"
+	"net/http"
+
+	"example.com/project/pkg/apperrors"
+)
+
+type Status int
+
+const fallbackStatus Status = http.StatusServiceUnavailable
+
+type Error struct {
+	Code apperrors.ErrorCode
+}
+
+func (e Error) HTTPStatusCode() Status {
+	switch e.Code {
+	case apperrors.CodeRecordNotFound:
+		return http.StatusNotFound
+	default:
+		return fallbackStatus
+	}
+}
+
+func (u *usecase) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := u.Get("id"); err != nil {
+		var de *apperrors.DomainError
+		if errors.As(err, &de) {
+			w.WriteHeader(de.HTTPStatusCode(http.StatusBadRequest))
+		}
+	}
+}
+`},
+	} {
+		f, err := parser.ParseFile(fset, file.name, file.src, parser.ParseComments)
+		require.NoError(t, err)
+		require.NoError(t, auditor.Run(file.pkgPath, f, fset))
+	}
+	result := auditor.Result()
+
+	reports := result.HTTPStatuses()
+	require.Len(t, reports, 2)
+	var statuses []string
+	for _, report := range reports {
+		for _, e := range report.Statuses {
+			statuses = append(statuses, report.Mapping.Name()+" "+statusName(e))
+		}
+	}
+	// the constant returned by the default clause is resolved.
+	require.Contains(t, statuses, "Error.HTTPStatusCode default http.StatusServiceUnavailable")
+	require.Contains(t, statuses, "DomainError.HTTPStatusCode default passed as defaultCode")
+
+	// the handler passing its default status gets it in the message.
+	var messages []string
+	for _, f := range result.Findings {
+		if f.Rule == HTTPStatusRule {
+			messages = append(messages, f.Func+": "+f.Message)
+		}
+	}
+	require.Contains(t, messages, "(*usecase).StatusHandler: can receive CodeInternalError, which DomainError.HTTPStatusCode does not map, falling through to the default status 400")
+	require.Contains(t, messages, "(*usecase).StatusHandler: can receive CodeInternalError, which Error.HTTPStatusCode does not map, falling through to the default status http.StatusServiceUnavailable")
+}
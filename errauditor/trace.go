@@ -0,0 +1,162 @@
+package errauditor
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+)
+
+// TracedError is an error a function can return with the trace of its
+// propagation.
+type TracedError struct {
+	Error string
+	// Trace holds the hops of the error, from the function returning it to
+	// the function returning it first.
+	Trace []*Hop
+	// Entry is the error returned by the last hop.
+	Entry *ErrorEntry
+	// Wrapped is set when the error is wrapped with %w by the last hop or by
+	// a call it is returned through, so that it no longer compares equal to
+	// the error it wraps.
+	Wrapped bool
+}
+
+// Hop is a function an error is returned through, with the position of the
+// call it returns the error of, or of the return statement in the last hop.
+type Hop struct {
+	// Func is the qualified name of the function.
+	Func string
+	Pos  token.Position
+}
+
+// String renders the hop as `project.(*repo).Find (repo.go:12)`.
+func (h *Hop) String() string {
+	return fmt.Sprintf("%s (%s:%d)", shortName(h.Func), h.Pos.Filename, h.Pos.Line)
+}
+
+// traced returns the traced errors of a function, or its own errors when the
+// traces were not computed.
+func (a *AggregatedError) traced() []*TracedError {
+	if a.Traces != nil {
+		return a.Traces
+	}
+	return a.ownTraces()
+}
+
+// Traced returns the errors a function may return, its own and the ones
+// returned through its calls, each with its trace.
+func (a *AggregatedError) Traced() []*TracedError {
+	return a.traced()
+}
+
+// ownTraces returns the errors of the function itself, traced to their
+// return statement.
+func (a *AggregatedError) ownTraces() []*TracedError {
+	traced := make([]*TracedError, len(a.Errors))
+	for i, e := range a.Errors {
+		traced[i] = &TracedError{Error: e.String(), Trace: []*Hop{{Func: a.Key(), Pos: e.Pos}}, Entry: e, Wrapped: e.Kind == WrappedError}
+	}
+	return traced
+}
+
+// aggregatedErrors returns the functions returning errors by qualified name.
+func (r *Result) aggregatedErrors() map[string]*AggregatedError {
+	agErrors := make(map[string]*AggregatedError, len(r.AggregatedErrors))
+	for _, agError := range r.AggregatedErrors {
+		agErrors[agError.Key()] = agError
+	}
+	return agErrors
+}
+
+// traceAggregatedErrors adds the errors returned through the calls of each
+// function to its traces. The functions are copied since their traces are
+// computed from the merged results.
+func (r *Result) traceAggregatedErrors() {
+	graph := newCallGraph(r.Decls)
+	agErrors := r.aggregatedErrors()
+	for i, agError := range r.AggregatedErrors {
+		d, ok := r.Decls[agError.Key()]
+		if !ok {
+			continue
+		}
+		copied := *agError
+		copied.Traces = traceErrors(d, graph, agErrors)
+		r.AggregatedErrors[i] = &copied
+	}
+}
+
+// traceErrors collects the errors returned by entry and by the functions whose
+// error it returns, breadth first so that each error gets its shortest trace.
+// Returned calls to such functions are followed instead of being reported.
+// Entry points that do not return an error, such as HTTP handlers, follow all
+// their calls.
+func traceErrors(entry *Decl, graph *callGraph, agErrors map[string]*AggregatedError) []*TracedError {
+	var traced []*TracedError
+	// seen holds the entries already traced, an error returned by several
+	// functions, even with the same text, is traced from each of them.
+	seen := make(map[token.Position]bool)
+	parent := map[*Decl]*Decl{entry: nil}
+	// via holds the call of the parent a declaration is reached by.
+	via := make(map[*Decl]*Call)
+	queue := []*Decl{entry}
+	for len(queue) > 0 {
+		d := queue[0]
+		queue = queue[1:]
+		followed := make(map[*Decl]bool)
+		for _, call := range d.Calls {
+			if !call.Returned && (d != entry || entry.Error) {
+				continue
+			}
+			callee := graph.resolve(call)
+			if callee == nil || !callee.Error {
+				continue
+			}
+			followed[callee] = true
+			if _, ok := parent[callee]; !ok {
+				parent[callee] = d
+				via[callee] = call
+				queue = append(queue, callee)
+			}
+		}
+		agError := agErrors[d.Key()]
+		if agError == nil {
+			continue
+		}
+		var hops []*Hop
+		wrapped := false
+		for p := d; parent[p] != nil; p = parent[p] {
+			hops = append([]*Hop{{Func: parent[p].Key(), Pos: via[p].Pos}}, hops...)
+			wrapped = wrapped || via[p].Wrapped
+		}
+		for _, own := range agError.ownTraces() {
+			if followed[graph.entryDecl(own.Entry)] || seen[own.Entry.Pos] {
+				continue
+			}
+			seen[own.Entry.Pos] = true
+			trace := append(append([]*Hop(nil), hops...), own.Trace...)
+			traced = append(traced, &TracedError{Error: own.Error, Trace: trace, Entry: own.Entry, Wrapped: wrapped || own.Wrapped})
+		}
+	}
+	return traced
+}
+
+// shortName returns a qualified name with the package name instead of its
+// import path, such as `project.(*usecase).Get` or `*apperrors.DomainError`.
+func shortName(key string) string {
+	i := strings.LastIndex(key, "/")
+	if i < 0 {
+		return key
+	}
+	stars := len(key) - len(strings.TrimLeft(key, "*"))
+	return key[:stars] + key[i+1:]
+}
+
+// traceString renders a trace as
+// `project.Handler (handler.go:10) -> project.(*usecase).Get (usecase.go:20)`.
+func traceString(trace []*Hop) string {
+	hops := make([]string, len(trace))
+	for i, hop := range trace {
+		hops[i] = hop.String()
+	}
+	return strings.Join(hops, " -> ")
+}
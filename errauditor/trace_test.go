@@ -0,0 +1,97 @@
+package errauditor
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraces(t *testing.T) {
+	result := auditEntryPoints(t)
+
+	agErrors := result.aggregatedErrors()
+	get := agErrors["example.com/project.(*usecase).Get"]
+	require.Equal(t, []string{`ErrInternalServerError("admin",)`}, get.ErrorStrings())
+	require.Len(t, get.Traces, 2)
+	require.Equal(t, `ErrInternalServerError("admin",)`, get.Traces[0].Error)
+	require.Equal(t, []*Hop{
+		{Func: "example.com/project.(*usecase).Get", Pos: get.Errors[0].Pos},
+	}, get.Traces[0].Trace)
+	require.Equal(t, get.Errors[0], get.Traces[0].Entry)
+	require.Equal(t, "ErrRecordNotFound()", get.Traces[1].Error)
+	require.Equal(t, "example.com/project/pkg/apperrors.ErrRecordNotFound", get.Traces[1].Entry.Target)
+	require.Equal(t, "project.(*usecase).Get (handler.go:23) -> project.(*repo).Find (handler.go:13)", traceString(get.Traces[1].Trace))
+
+	// the wrapped error is traced through the wrapping call.
+	update := agErrors["example.com/project.(*usecase).Update"]
+	require.Len(t, update.Traces, 3)
+	require.Equal(t, "project.(*usecase).Update (update.go:6) -> project.(*usecase).Get (handler.go:28)", traceString(update.Traces[1].Trace))
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteMarkdown(&buf, result))
+	require.Contains(t, buf.String(), "- `ErrRecordNotFound()` from `project.(*usecase).Get (handler.go:23) -> project.(*repo).Find (handler.go:13)`")
+	buf.Reset()
+	require.NoError(t, WriteHTML(&buf, result))
+	require.Contains(t, buf.String(), `<span class="pos">project.(*usecase).Get (handler.go:23) -&gt; project.(*repo).Find (handler.go:13)</span>`)
+}
+
+func TestTraceOrigins(t *testing.T) {
+	auditor := NewAuditor()
+	fset := token.NewFileSet()
+	for _, file := range []struct{ pkgPath, name, src string }{
+		{"example.com/project/users", "users.go", `package users
+
+import "errors"
+
+func Find(id string) error {
+	return errors.New("not found")
+}
+`},
+		{"example.com/project/orders", "orders.go", `package orders
+
+import "errors"
+
+func Find(id string) error {
+	return errors.New("not found")
+}
+`},
+		{"example.com/project", "usecase.go", `package project
+
+import (
+	"example.com/project/cache"
+	"example.com/project/orders"
+	"example.com/project/users"
+)
+
+func Get(id string) error {
+	switch id {
+	case "user":
+		return users.Find(id)
+	case "order":
+		return orders.Find(id)
+	}
+	return cache.Find(id)
+}
+`},
+	} {
+		f, err := parser.ParseFile(fset, file.name, file.src, 0)
+		require.NoError(t, err)
+		require.NoError(t, auditor.Run(file.pkgPath, f, fset))
+	}
+	get := auditor.Result().aggregatedErrors()["example.com/project.Get"]
+
+	// the errors with the same text are traced from each origin, and the
+	// call to the unaudited cache.Find is not taken for the followed calls.
+	var traces []string
+	for _, e := range get.Traces {
+		traces = append(traces, e.Error+" "+traceString(e.Trace))
+	}
+	require.Equal(t, []string{
+		"Find() project.Get (usecase.go:16)",
+		`New("not found",) project.Get (usecase.go:12) -> users.Find (users.go:6)`,
+		`New("not found",) project.Get (usecase.go:14) -> orders.Find (orders.go:6)`,
+	}, traces)
+}
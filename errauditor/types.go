@@ -0,0 +1,190 @@
+package errauditor
+
+import (
+	"fmt"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// ErrorReceiverRule is reported for the Is, As and Unwrap methods with a
+// pointer receiver of error types returned as values, which the errors package
+// does not find on such errors.
+const ErrorReceiverRule = "error-receiver-mismatch"
+
+// errorMethods are the methods of error types used by the errors package.
+var errorMethods = map[string]bool{
+	"Error":  true,
+	"Is":     true,
+	"As":     true,
+	"Unwrap": true,
+}
+
+// CustomErrorType is a type implementing error.
+type CustomErrorType struct {
+	// Type is the qualified name of the type, such as
+	// `example.com/project/pkg/apperrors.DomainError`.
+	Type string
+	// Pos is the position of the Error method.
+	Pos token.Position
+	// Pointer is set when Error has a pointer receiver, so that only the
+	// pointer type implements error.
+	Pointer bool
+	// Methods holds the Error, Is, As and Unwrap methods of the type.
+	Methods []*ErrorMethod
+	// Mismatches holds the methods whose receiver differs from the receiver
+	// of Error.
+	Mismatches []*ErrorMethod
+	// Returners holds the qualified names of the functions and methods
+	// returning the type, as such or as an error. The package level variables
+	// holding it are not listed.
+	Returners []string
+	// ValueReturners holds the returners returning values of the type rather
+	// than pointers.
+	ValueReturners []string
+}
+
+// ErrorMethod is a method of an error type.
+type ErrorMethod struct {
+	Name string
+	// Result is the type of the first result, `[]error` for the Unwrap method
+	// of errors wrapping several errors.
+	Result  string
+	Pointer bool
+	Pos     token.Position
+	// recv is the receiver type, with its star for pointer receivers.
+	recv string
+}
+
+// String renders the method like `(*DomainError).Error`, with the result of
+// Unwrap, such as `DomainError.Unwrap []error`.
+func (m *ErrorMethod) String() string {
+	name := (&AggregatedError{Recv: m.recv, Func: m.Name}).Name()
+	if m.Name == "Unwrap" {
+		return name + " " + m.Result
+	}
+	return name
+}
+
+// receiver renders the receiver kind of a method.
+func (m *ErrorMethod) receiver() string {
+	if m.Pointer {
+		return "pointer"
+	}
+	return "value"
+}
+
+// ErrorTypeHierarchy reports the types implementing error, with their Error,
+// Is, As and Unwrap methods, the methods whose receiver differs from the
+// receiver of Error, and the functions returning each type.
+func (r *Result) ErrorTypeHierarchy() []*CustomErrorType {
+	byType := make(map[string]*CustomErrorType)
+	methods := make(map[string][]*ErrorMethod)
+	for _, d := range r.Decls {
+		if d.Recv == "" || !errorMethods[d.Name] {
+			continue
+		}
+		key := d.Package + "." + strings.TrimPrefix(d.Recv, "*")
+		m := &ErrorMethod{Name: d.Name, Result: d.Type, Pointer: strings.HasPrefix(d.Recv, "*"), Pos: d.Pos, recv: d.Recv}
+		methods[key] = append(methods[key], m)
+		if d.Name == "Error" && d.Type == "string" {
+			byType[key] = &CustomErrorType{Type: key, Pos: d.Pos, Pointer: m.Pointer}
+		}
+	}
+	if len(byType) == 0 {
+		return nil
+	}
+
+	graph := newCallGraph(r.Decls)
+	// returners maps the functions returning a type to whether they return
+	// values of it.
+	returners := make(map[string]map[string]bool)
+	add := func(typ, fn string) {
+		value := !strings.HasPrefix(typ, "*")
+		typ = strings.TrimPrefix(typ, "*")
+		if byType[typ] == nil {
+			return
+		}
+		if returners[typ] == nil {
+			returners[typ] = make(map[string]bool)
+		}
+		returners[typ][fn] = returners[typ][fn] || value
+	}
+	for key, d := range r.Decls {
+		// the methods of a type, such as setters, are not reported.
+		if d.Var {
+			continue
+		}
+		if d.Recv == "" || d.Package+"."+strings.TrimPrefix(d.Recv, "*") != strings.TrimPrefix(d.Type, "*") {
+			add(d.Type, key)
+		}
+	}
+	// functions returning the result of a call, such as a constructor.
+	for key, d := range r.Decls {
+		if d.Var {
+			continue
+		}
+		for _, call := range d.Calls {
+			if callee := graph.resolve(call); call.Returned && callee != nil {
+				add(callee.Type, key)
+			}
+		}
+	}
+	for _, agError := range r.AggregatedErrors {
+		for _, e := range agError.Errors {
+			add(graph.errorType(e), agError.Key())
+		}
+	}
+
+	types := make([]*CustomErrorType, 0, len(byType))
+	for key, t := range byType {
+		t.Methods = methods[key]
+		sort.Slice(t.Methods, func(i, j int) bool {
+			return positionLess(t.Methods[i].Pos, t.Methods[j].Pos)
+		})
+		for _, m := range t.Methods {
+			if m.Pointer != t.Pointer {
+				t.Mismatches = append(t.Mismatches, m)
+			}
+		}
+		for fn, value := range returners[key] {
+			t.Returners = append(t.Returners, fn)
+			if value {
+				t.ValueReturners = append(t.ValueReturners, fn)
+			}
+		}
+		sort.Strings(t.Returners)
+		sort.Strings(t.ValueReturners)
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i].Type < types[j].Type
+	})
+	return types
+}
+
+// checkErrorTypes reports the Is, As and Unwrap methods with a pointer
+// receiver of error types whose values are returned: the method set of the
+// values does not have them, so errors.Is and errors.As skip them. A value
+// receiver on these methods is harmless and only noted in the reports.
+func (r *Result) checkErrorTypes() {
+	for _, t := range r.ErrorTypeHierarchy() {
+		if len(t.ValueReturners) == 0 {
+			continue
+		}
+		name := shortName(t.Type)
+		name = name[strings.LastIndex(name, ".")+1:]
+		for _, m := range t.Methods {
+			if !m.Pointer || m.Name == "Error" {
+				continue
+			}
+			r.Findings = append(r.Findings, &Finding{
+				Rule:    ErrorReceiverRule,
+				Package: t.Type[:strings.LastIndex(t.Type, ".")],
+				Func:    (&AggregatedError{Recv: m.recv, Func: m.Name}).Name(),
+				Pos:     m.Pos,
+				Message: fmt.Sprintf("%s has a pointer receiver but %s returns %s values, errors.Is and errors.As do not call it on them", m.Name, shortName(t.ValueReturners[0]), name),
+			})
+		}
+	}
+}
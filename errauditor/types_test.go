@@ -0,0 +1,159 @@
+package errauditor
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const typesSrc = `package project
+
+import "errors"
+
+type DomainError struct {
+	Code string
+	err  error
+}
+
+func (e *DomainError) Error() string {
+	return e.Code
+}
+
+func (e DomainError) Is(target error) bool {
+	return e.Code == target.Error()
+}
+
+func (e *DomainError) Unwrap() error {
+	return e.err
+}
+
+func (e *DomainError) WithCode(code string) *DomainError {
+	e.Code = code
+	return e
+}
+
+type MultiError []error
+
+func (m MultiError) Error() string {
+	return "multiple errors"
+}
+
+func (m MultiError) Unwrap() []error {
+	return m
+}
+
+func NewDomainError(code string) *DomainError {
+	return &DomainError{Code: code}
+}
+
+func Get(id string) error {
+	if id == "" {
+		return NewDomainError("empty")
+	}
+	return Errors(errors.New("a"), errors.New("b"))
+}
+
+func Errors(errs ...error) MultiError {
+	return errs
+}
+
+var ErrEmpty = NewDomainError("empty")
+`
+
+func TestErrorTypeHierarchy(t *testing.T) {
+	result := runSource(t, "example.com/project", typesSrc)
+	types := result.ErrorTypeHierarchy()
+	require.Len(t, types, 2)
+
+	domain := types[0]
+	require.Equal(t, "example.com/project.DomainError", domain.Type)
+	require.True(t, domain.Pointer)
+	var methods []string
+	for _, m := range domain.Methods {
+		methods = append(methods, m.String())
+	}
+	require.Equal(t, []string{"(*DomainError).Error", "DomainError.Is", "(*DomainError).Unwrap error"}, methods)
+	require.Len(t, domain.Mismatches, 1)
+	require.Equal(t, "Is", domain.Mismatches[0].Name)
+	// the setters of the type and the variables holding it are not reported.
+	require.Equal(t, []string{"example.com/project.Get", "example.com/project.NewDomainError"}, domain.Returners)
+
+	multi := types[1]
+	require.Equal(t, "example.com/project.MultiError", multi.Type)
+	require.False(t, multi.Pointer)
+	require.Equal(t, "[]error", multi.Methods[1].Result)
+	require.Empty(t, multi.Mismatches)
+	require.Equal(t, []string{"example.com/project.Errors", "example.com/project.Get"}, multi.Returners)
+
+	var findings []*Finding
+	for _, f := range result.Findings {
+		if f.Rule == ErrorReceiverRule {
+			findings = append(findings, f)
+		}
+	}
+	// the value receiver of Is is harmless and only noted in the reports,
+	// and DomainError is only returned as a pointer.
+	require.Empty(t, findings)
+	require.Empty(t, domain.ValueReturners)
+
+	result.ErrorTypes = types
+	var buf bytes.Buffer
+	require.NoError(t, WriteText(&buf, result))
+	require.Contains(t, buf.String(), "usecase.go:10:1:  project.DomainError error type\n---(*DomainError).Error \n---DomainError.Is (value receiver, Error has a pointer receiver) \n")
+	require.Contains(t, buf.String(), "      returned by project.Get, project.NewDomainError\n")
+
+	buf.Reset()
+	require.NoError(t, WriteJSON(&buf, result))
+	var report JSONReport
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &report))
+	require.Len(t, report.ErrorTypes, 2)
+	require.True(t, report.ErrorTypes[0].Methods[1].Mismatch)
+	require.Equal(t, []string{"project.Errors", "project.Get"}, report.ErrorTypes[1].ReturnedBy)
+}
+
+const valueTypesSrc = `package project
+
+type CodeError struct {
+	Code string
+	err  error
+}
+
+func (e CodeError) Error() string {
+	return e.Code
+}
+
+func (e *CodeError) Is(target error) bool {
+	return e.Code == target.Error()
+}
+
+func (e *CodeError) Unwrap() error {
+	return e.err
+}
+
+func NewCodeError(code string) CodeError {
+	return CodeError{Code: code}
+}
+
+func NewCodeErrorPtr(code string) *CodeError {
+	return &CodeError{Code: code}
+}
+`
+
+func TestErrorReceiverValues(t *testing.T) {
+	result := runSource(t, "example.com/project", valueTypesSrc)
+	types := result.ErrorTypeHierarchy()
+	require.Len(t, types, 1)
+	require.Equal(t, []string{"example.com/project.NewCodeError"}, types[0].ValueReturners)
+
+	var messages []string
+	for _, f := range result.Findings {
+		require.Equal(t, ErrorReceiverRule, f.Rule)
+		messages = append(messages, f.Func+": "+f.Message)
+	}
+	require.Equal(t, []string{
+		"(*CodeError).Is: Is has a pointer receiver but project.NewCodeError returns CodeError values, errors.Is and errors.As do not call it on them",
+		"(*CodeError).Unwrap: Unwrap has a pointer receiver but project.NewCodeError returns CodeError values, errors.Is and errors.As do not call it on them",
+	}, messages)
+}
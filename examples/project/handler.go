@@ -0,0 +1,27 @@
+package project
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/thedhejavu/errauditor/examples/project/pkg/apperrors"
+)
+
+func GetAddressHandler(w http.ResponseWriter, r *http.Request) {
+	if err := GetAddressByUser(); err != nil {
+		var de *apperrors.DomainError
+		if errors.As(err, &de) {
+			http.Error(w, de.Message, de.HTTPStatusCode(http.StatusInternalServerError))
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func NewRouter() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}/address", GetAddressHandler)
+	return mux
+}
@@ -1,11 +1,14 @@
 module github.com/thedhejavu/errauditor
 
-go 1.18
+go 1.23.0
 
 require (
 	github.com/fatih/color v1.13.0
+	github.com/fsnotify/fsnotify v1.6.0
+	github.com/golangci/plugin-module-register v0.1.2
 	github.com/sirupsen/logrus v1.8.1
 	github.com/stretchr/testify v1.7.2
+	golang.org/x/tools v0.32.0
 )
 
 require (
@@ -14,7 +17,9 @@ require (
 	github.com/mattn/go-colorable v0.1.9 // indirect
 	github.com/mattn/go-isatty v0.0.14 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	golang.org/x/sys v0.0.0-20220610221304-9f5ed59c137d // indirect
+	golang.org/x/mod v0.24.0 // indirect
+	golang.org/x/sync v0.13.0 // indirect
+	golang.org/x/sys v0.32.0 // indirect
 	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
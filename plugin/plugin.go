@@ -0,0 +1,178 @@
+// Package plugin registers errauditor as a golangci-lint module plugin.
+//
+// Add it to .custom-gcl.yml:
+//
+//	plugins:
+//	  - module: github.com/thedhejavu/errauditor
+//	    import: github.com/thedhejavu/errauditor/plugin
+//	    version: latest
+//
+// and enable it in .golangci.yml with the same settings as the -config file of
+// the command line:
+//
+//	linters-settings:
+//	  custom:
+//	    errauditor:
+//	      type: module
+//	      settings:
+//	        baseline: .errauditor-baseline.json
+//
+// The results of the imported packages are passed along as facts, and findings
+// are only reported in the analyzed package.
+package plugin
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/golangci/plugin-module-register/register"
+	"github.com/thedhejavu/errauditor/errauditor"
+	"golang.org/x/tools/go/analysis"
+)
+
+func init() {
+	register.Plugin("errauditor", New)
+}
+
+// Settings are the plugin settings, they match the command line config file.
+type Settings = errauditor.Config
+
+// Plugin is the golangci-lint module plugin.
+type Plugin struct {
+	settings Settings
+}
+
+// New decodes the settings of the plugin.
+func New(conf any) (register.LinterPlugin, error) {
+	settings, err := register.DecodeSettings[Settings](conf)
+	if err != nil {
+		return nil, err
+	}
+	return &Plugin{settings: settings}, nil
+}
+
+// BuildAnalyzers returns the errauditor analyzer.
+func (p *Plugin) BuildAnalyzers() ([]*analysis.Analyzer, error) {
+	analyzer, err := NewAnalyzer(p.settings)
+	if err != nil {
+		return nil, err
+	}
+	return []*analysis.Analyzer{analyzer}, nil
+}
+
+// GetLoadMode returns the load mode of the plugin. The auditor only needs the
+// syntax tree, the types are needed for the facts of the dependencies.
+func (p *Plugin) GetLoadMode() string {
+	return register.LoadModeTypesInfo
+}
+
+// packageFact holds the raw result of a package. The packages importing it
+// merge the results of their dependencies, so that their rules trace errors
+// through the functions of other packages and resolve the errors, constants
+// and status mappings declared there.
+type packageFact struct {
+	Result *errauditor.Result
+}
+
+func (*packageFact) AFact() {}
+
+func (f *packageFact) String() string {
+	return fmt.Sprintf("errauditor %d funcs", len(f.Result.AggregatedErrors))
+}
+
+// NewAnalyzer returns an analyzer reporting the findings of the auditor as
+// diagnostics.
+func NewAnalyzer(settings Settings) (*analysis.Analyzer, error) {
+	excludePatterns := make([]*regexp.Regexp, 0, len(settings.Exclude))
+	for _, d := range settings.Exclude {
+		p, err := regexp.Compile(d)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse exclude dir pattern: %v", err)
+		}
+		excludePatterns = append(excludePatterns, p)
+	}
+	if _, err := errauditor.NewRegistry(settings.Constructors); err != nil {
+		return nil, err
+	}
+	var baseline *errauditor.Baseline
+	if settings.Baseline != "" {
+		f, err := os.Open(settings.Baseline)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		baseline, err = errauditor.ReadBaseline(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read baseline: %v", err)
+		}
+	}
+
+	return &analysis.Analyzer{
+		Name:      "errauditor",
+		Doc:       "reports the findings of errauditor on the errors returned by functions",
+		FactTypes: []analysis.Fact{new(packageFact)},
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			auditor := errauditor.NewAuditor()
+			if err := auditor.Register(settings.Constructors...); err != nil {
+				return nil, err
+			}
+			files := make(map[string]*ast.File, len(pass.Files))
+		files:
+			for _, f := range pass.Files {
+				filename := pass.Fset.Position(f.Pos()).Filename
+				for _, p := range excludePatterns {
+					if p.MatchString(filepath.Dir(filename)) {
+						continue files
+					}
+				}
+				files[filename] = f
+				if err := auditor.Run(pass.Pkg.Path(), f, pass.Fset); err != nil {
+					return nil, err
+				}
+			}
+
+			// the ignore directives only apply to the files of the package.
+			raw := auditor.Raw()
+			raw.Ignores = nil
+			pass.ExportPackageFact(&packageFact{Result: raw})
+			for _, fact := range pass.AllPackageFacts() {
+				if dep, ok := fact.Fact.(*packageFact); ok && fact.Package != pass.Pkg {
+					auditor.Merge(dep.Result)
+				}
+			}
+
+			result := auditor.Result()
+			if settings.ReportUnusedIgnores {
+				result.ReportUnusedIgnores()
+			}
+			if baseline != nil {
+				baseline.Filter(result)
+			}
+			for _, finding := range result.Findings {
+				f, ok := files[finding.Pos.Filename]
+				if !ok {
+					continue
+				}
+				pass.Report(analysis.Diagnostic{
+					Pos:      position(pass.Fset, f, finding.Pos),
+					Category: finding.Rule,
+					Message:  fmt.Sprintf("%s: %s", finding.Rule, finding.Message),
+				})
+			}
+			return nil, nil
+		},
+	}, nil
+}
+
+// position converts the position of a finding back to a token.Pos of f.
+func position(fset *token.FileSet, f *ast.File, posn token.Position) token.Pos {
+	tf := fset.File(f.Pos())
+	if posn.Offset < 0 || posn.Offset > tf.Size() {
+		return f.Pos()
+	}
+	return tf.Pos(posn.Offset)
+}
@@ -0,0 +1,34 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/golangci/plugin-module-register/register"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestNew(t *testing.T) {
+	newPlugin, err := register.GetPlugin("errauditor")
+	require.NoError(t, err)
+
+	p, err := newPlugin(map[string]any{"exclude": []string{"mocks"}})
+	require.NoError(t, err)
+	require.Equal(t, register.LoadModeTypesInfo, p.GetLoadMode())
+	analyzers, err := p.BuildAnalyzers()
+	require.NoError(t, err)
+	require.Len(t, analyzers, 1)
+
+	_, err = newPlugin(map[string]any{"unknown": true})
+	require.Error(t, err)
+	p, err = newPlugin(map[string]any{"exclude": []string{"("}})
+	require.NoError(t, err)
+	_, err = p.BuildAnalyzers()
+	require.Error(t, err)
+}
+
+func TestAnalyzer(t *testing.T) {
+	analyzer, err := NewAnalyzer(Settings{})
+	require.NoError(t, err)
+	analysistest.Run(t, analysistest.TestData(), analyzer, "usecase")
+}
@@ -0,0 +1,7 @@
+package apperrors
+
+import "errors"
+
+var ErrNotFound = errors.New("not found")
+
+var ErrConflict = errors.New("conflict")
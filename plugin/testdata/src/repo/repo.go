@@ -0,0 +1,11 @@
+package repo
+
+import (
+	"fmt"
+
+	"apperrors"
+)
+
+func Find(id string) error {
+	return fmt.Errorf("find %s: %w", id, apperrors.ErrNotFound)
+}
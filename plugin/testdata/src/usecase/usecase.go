@@ -0,0 +1,21 @@
+package usecase // want package:"errauditor 1 funcs"
+
+import (
+	"errors"
+
+	"apperrors"
+	"repo"
+)
+
+var ErrNotFound = errors.New("not found")
+
+func Get(id string) error {
+	err := repo.Find(id)
+	if err == apperrors.ErrNotFound { // want `wrapped-error-comparison: == compares with apperrors.ErrNotFound which repo.Find returns wrapped, use errors.Is`
+		return ErrNotFound
+	}
+	if errors.Is(err, apperrors.ErrConflict) { // want `dead-error-check: errors.Is checks apperrors.ErrConflict which repo.Find never returns`
+		return nil
+	}
+	return errors.New("unable to get")
+}